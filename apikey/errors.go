@@ -0,0 +1,14 @@
+package apikey
+
+import (
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+var (
+	// ErrMalformedHeader is returned when the Authorization header isn't an Apikey
+	ErrMalformedHeader = authorization.NewError("authorization header format must be Apikey {key}", authorization.ErrMalformedHeader)
+	// ErrUnknownKey is returned when the key isn't in the Store
+	ErrUnknownKey = authorization.NewError("unknown api key", authorization.ErrUnauthenticated)
+	// ErrDisabledKey is returned when the key is disabled
+	ErrDisabledKey = authorization.NewError("api key is disabled", authorization.ErrUnauthenticated)
+)
@@ -0,0 +1,60 @@
+// Package apikey implements an authorization.Parser for API keys, sent as an "Apikey {key}"
+// Authorization header or in the X-Api-Key header
+package apikey
+
+import (
+	"context"
+	"strings"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+	"github.com/travelgateX/go-jwt-tools/jwt"
+)
+
+var (
+	_ authorization.Parser        = (*Parser)(nil)
+	_ authorization.ContextParser = (*Parser)(nil)
+)
+
+// Parser creates service principal Users from the keys of a Store
+type Parser struct {
+	store      Store
+	adminGroup string
+}
+
+// NewParser returns a Parser looking up keys in store, adminGroup is handed to jwt.NewPermissions
+func NewParser(store Store, adminGroup string) *Parser {
+	return &Parser{store: store, adminGroup: adminGroup}
+}
+
+func (p *Parser) Parse(authorizationHeader string) (*authorization.User, error) {
+	return p.ParseContext(context.Background(), authorizationHeader)
+}
+
+// ParseContext parses the header, ctx is handed to the Store lookup
+func (p *Parser) ParseContext(ctx context.Context, authorizationHeader string) (*authorization.User, error) {
+	scheme, key, ok := strings.Cut(authorizationHeader, " ")
+	if !ok || !strings.EqualFold(scheme, authorization.SchemeApikey) || key == "" {
+		return nil, ErrMalformedHeader
+	}
+
+	k, err := p.store.Lookup(ctx, HashKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if k.Disabled {
+		return nil, ErrDisabledKey
+	}
+
+	ids := []string{k.ID}
+	var groups []interface{}
+	if k.Groups != nil {
+		groups = []interface{}{k.Groups}
+	}
+	return &authorization.User{
+		AuthorizationValue: authorizationHeader,
+		Permissions:        jwt.NewPermissions(groups, ids, p.adminGroup),
+		UserID:             ids,
+		Orgs:               k.Orgs,
+		Principal:          authorization.PrincipalService,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+func TestParser(t *testing.T) {
+	store := NewMemoryStore(
+		Key{
+			Hash: HashKey("s3cret"),
+			ID:   "billing-sync",
+			Orgs: []authorization.Organization{{Code: "org1", Role: authorization.EDITOR}},
+			Groups: []interface{}{map[string]interface{}{
+				"c": "org1", "t": "org", "p": map[string]interface{}{"billing": map[string]interface{}{"invoice": []interface{}{"r1"}}},
+			}},
+		},
+		Key{Hash: HashKey("old"), ID: "legacy", Disabled: true},
+	)
+	p := NewParser(store, "")
+
+	u, err := p.Parse("Apikey s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"billing-sync"}, u.UserID)
+	assert.Equal(t, authorization.PrincipalService, u.Principal)
+	assert.True(t, u.IsServicePrincipal())
+	assert.Equal(t, []string{"org1"}, u.GetOrgs(authorization.EDITOR))
+	_, ok := u.Permissions.CheckPermission("billing", "invoice", authorization.Read)
+	assert.True(t, ok)
+
+	_, err = p.Parse("apikey s3cret")
+	assert.NoError(t, err)
+
+	_, err = p.Parse("Apikey wrong")
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+	assert.True(t, errors.Is(err, authorization.ErrUnauthenticated))
+
+	_, err = p.Parse("Apikey old")
+	assert.True(t, errors.Is(err, ErrDisabledKey))
+
+	_, err = p.Parse("Bearer s3cret")
+	assert.True(t, errors.Is(err, authorization.ErrMalformedHeader))
+}
+
+func TestParser_headerMiddleware(t *testing.T) {
+	p := NewParser(NewMemoryStore(Key{Hash: HashKey("s3cret"), ID: "billing-sync"}), "")
+
+	var user *authorization.User
+	h := authorization.Middleware(p)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ = authorization.UserFromContext(r.Context())
+		assert.True(t, authorization.IsApikeyFromContext(r.Context()))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(authorization.HeaderApikey, "s3cret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.NotNil(t, user)
+	assert.Equal(t, []string{"billing-sync"}, user.UserID)
+}
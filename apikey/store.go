@@ -0,0 +1,75 @@
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+// Key describes the service principal an API key authenticates. Only the hash of the key is stored
+type Key struct {
+	// Hash is the HashKey of the API key
+	Hash string `json:"hash"`
+	// ID identifies the service, it's the User member id
+	ID string `json:"id"`
+	// Orgs is encoded in JSON like an organizations jwt claim and Groups follows the format of a groups jwt claim
+	Orgs   []authorization.Organization `json:"orgs"`
+	Groups []interface{}                `json:"groups"`
+	// Disabled keys are rejected
+	Disabled bool `json:"disabled"`
+}
+
+// Store looks up keys by hash. Lookup returns ErrUnknownKey when there is no key with that hash
+type Store interface {
+	Lookup(ctx context.Context, hash string) (*Key, error)
+}
+
+// HashKey returns the hex encoded SHA-256 of an API key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is a Store backed by a map, safe for concurrent use
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewMemoryStore returns a MemoryStore holding keys
+func NewMemoryStore(keys ...Key) *MemoryStore {
+	s := &MemoryStore{keys: make(map[string]Key, len(keys))}
+	for _, k := range keys {
+		s.keys[k.Hash] = k
+	}
+	return s
+}
+
+// Add stores k, replacing any key with the same hash
+func (s *MemoryStore) Add(k Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.Hash] = k
+}
+
+// Remove deletes the key with the given hash
+func (s *MemoryStore) Remove(hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, hash)
+}
+
+func (s *MemoryStore) Lookup(ctx context.Context, hash string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[hash]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return &k, nil
+}
@@ -12,33 +12,94 @@ type User struct {
 	Permissions        Permissions
 	AuthorizationValue string
 	UserID             []string
-	Orgs               []interface{}
+	Issuer             string
+	Scopes             []string
+	Orgs               []Organization
 	Expiration         float64
+	IssuedAt           float64
+	TokenID            string
 	IsExpired          bool
 	IsDummy            bool
 	TgxMember          bool
-}
+	Principal          Principal
+	// ShortTokenID and ShortIssuedAt are the jti and iat of the short token a fetch needed User was
+	// upgraded from, TokenID and IssuedAt are those of the fetched token
+	ShortTokenID  string
+	ShortIssuedAt float64
+}
+
+// Principal is the kind of identity a User represents
+type Principal int
+
+const (
+	// PrincipalUnknown is left by parsers that don't know the kind of identity, see IsApikeyFromContext
+	PrincipalUnknown Principal = iota
+	// PrincipalUser is a person
+	PrincipalUser
+	// PrincipalService is a service authenticated with an API key
+	PrincipalService
+)
+
+const (
+	// SchemeApikey is the Authorization scheme of API keys
+	SchemeApikey = "Apikey"
+	// HeaderApikey is read by Middleware as an "Apikey {key}" Authorization header when no Authorization
+	// header is sent
+	HeaderApikey = "X-Api-Key"
+)
 
 // Parser creates a User from an authorization header
 type Parser interface {
 	Parse(authHeader string) (*User, error)
 }
 
+// ContextParser is a Parser whose parsing can be cancelled and carry request scoped values, such as
+// trace metadata, through the context
+type ContextParser interface {
+	ParseContext(ctx context.Context, authHeader string) (*User, error)
+}
+
+// AsContextParser returns p as a ContextParser. Parsers not implementing it are adapted by ignoring
+// the context once parsing started
+func AsContextParser(p Parser) ContextParser {
+	if cp, ok := p.(ContextParser); ok {
+		return cp
+	}
+	return legacyParser{p}
+}
+
+type legacyParser struct {
+	p Parser
+}
+
+func (l legacyParser) ParseContext(ctx context.Context, authHeader string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return l.p.Parse(authHeader)
+}
+
 // Middleware creates a User from a Parser and puts it in the request context
-// which can be later obtained by calling to UserFromContext()
-// Errors returned from Parser are printed to the response body
+// which can be later obtained by calling to UserFromContext(). Parsers implementing ContextParser
+// receive the request context
+// Errors returned from Parser are printed to the response body, the status code is 503 for
+// ErrUnavailable errors, 400 for ErrMalformedHeader errors and 401 otherwise
 func Middleware(p Parser) func(h http.Handler) http.Handler {
+	cp := AsContextParser(p)
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" && r.Header.Get(HeaderApikey) != "" {
+				authHeader = SchemeApikey + " " + r.Header.Get(HeaderApikey)
+			}
 			if authHeader == "" {
 				http.Error(w, errMessageNoAuthorizationHeader, http.StatusUnauthorized)
 				return
 			}
 
-			u, err := p.Parse(authHeader)
+			u, err := cp.ParseContext(r.Context(), authHeader)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusUnauthorized)
+				http.Error(w, err.Error(), statusCode(err))
 				return
 			}
 
@@ -56,9 +117,27 @@ func UserFromContext(ctx context.Context) (*User, bool) {
 	return val, ok
 }
 
+// IsApikeyFromContext reports whether the User in the context is a service principal. For users of
+// unknown principal the member id is checked not to be an email
 func IsApikeyFromContext(ctx context.Context) bool {
-	val, _ := ctx.Value(activeUser).(*User)
-	return !isValidEmail(val.UserID[0])
+	val, ok := ctx.Value(activeUser).(*User)
+	if !ok || val == nil {
+		return false
+	}
+	return val.IsServicePrincipal()
+}
+
+// IsServicePrincipal reports whether the User is a service principal. For users of unknown principal the
+// member id is checked not to be an email
+func (u User) IsServicePrincipal() bool {
+	switch u.Principal {
+	case PrincipalService:
+		return true
+	case PrincipalUser:
+		return false
+	default:
+		return len(u.UserID) > 0 && !isValidEmail(u.UserID[0])
+	}
 }
 
 func IsTGXMember(ctx context.Context) bool {
@@ -93,18 +172,9 @@ func (u User) GetOrgs(role Role) []string {
 
 func (u User) GetOrgsServiceFilter(role Role, service *Service) []string {
 	orgCodes := []string{}
-
-	if len(u.Orgs) == 0 {
-		return orgCodes
-	}
-
-	for _, org := range u.Orgs[0].([]interface{}) {
-		orgRole, orgName := extractOrgInfo(org, service)
-
-		if orgRole >= role {
-			if orgName != "" {
-				orgCodes = append(orgCodes, orgName)
-			}
+	for _, org := range u.Orgs {
+		if org.EffectiveRole(service).Includes(role) {
+			orgCodes = append(orgCodes, org.Code)
 		}
 	}
 	return orgCodes
@@ -116,71 +186,17 @@ func IsTGXMemberRole(ctx context.Context, role Role, service *Service) bool {
 	return user.IsTGXMemberRole(role, service)
 
 }
-func (u User) IsTGXMemberRole(role Role, service *Service) bool {
-	if !u.IsTGXMember() {
-		return false
-	}
-
-	for _, org := range u.Orgs[0].([]interface{}) {
-		orgRole, orgName := extractOrgInfo(org, service)
-
-		if orgName == ORG_TGX && orgRole >= role {
-			return true
-		}
-	}
-
-	return false
-}
 
-func extractOrgInfo(org interface{}, service *Service) (Role, string) {
-	if orgMap, ok := org.(map[string]interface{}); ok {
-		orgRole := VIEWER
-		if orgString, ok := orgMap["r"].(string); ok {
-			orgRole = GetRoleFromString(orgString)
-		}
-		if service != nil {
-			orgRole = getServiceRole(*service, orgMap, orgRole)
-		}
-		if orgName, ok := orgMap["o"].(string); ok {
-			return orgRole, orgName
-		}
-	}
-	return VIEWER, ""
+// IsTGXMemberRole reports whether the User is platform staff granted role in service within the
+// platform organizations of the PlatformPolicy set with SetPlatformPolicy
+func (u User) IsTGXMemberRole(role Role, service *Service) bool {
+	return GetPlatformPolicy().HasStaffRole(u, role, service)
 }
-func getServiceRole(inputService Service, orgMap map[string]interface{}, orgRole Role) Role {
-	services, ok := orgMap["s"].([]interface{})
-	if !ok {
-		return orgRole
-	}
-
-	for _, serviceInt := range services {
-		serviceMap, ok := serviceInt.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		if service, ok := serviceMap["c"].(string); !ok || GetServiceFromString(service) != inputService {
-			continue
-		}
 
-		serviceRoleStr, ok := serviceMap["r"].(string)
-		if !ok {
-			continue
-		}
-
-		serviceRole := GetRoleFromString(serviceRoleStr)
-		if orgRole < serviceRole {
-			return serviceRole
-		}
-	}
-
-	return orgRole
-}
+var emailPattern = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 
 func isValidEmail(email string) bool {
-	regex := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
-	pattern := regexp.MustCompile(regex)
-	return pattern.MatchString(email)
+	return emailPattern.MatchString(email)
 }
 
 // ContextWithUser returns a new `context.Context` that holds a reference to the user `u`
@@ -3,6 +3,7 @@ package authorization
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -52,6 +53,84 @@ func TestMiddleware_ParserError(t *testing.T) {
 	assert.True(t, strings.Contains(rec.Body.String(), parserErr.Error()))
 }
 
+func TestMiddleware_ParserErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{NewError("bad scheme", ErrMalformedHeader), http.StatusBadRequest},
+		{NewError("expired", ErrUnauthenticated), http.StatusUnauthorized},
+		{ErrInvalidUser, http.StatusUnauthorized},
+		{fmt.Errorf("fetching: %w", ErrUnavailable), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		parser := &MockParser{
+			ParseFn: func(authHeader string) (*User, error) {
+				return nil, tt.err
+			},
+		}
+
+		req, err := http.NewRequest("POST", "", nil)
+		assert.NoError(t, err)
+		req.Header.Add("Authorization", "bearer")
+		rec := httptest.NewRecorder()
+
+		Middleware(parser)((&testNextHandler{}).Handler()).ServeHTTP(rec, req)
+		assert.Equal(t, tt.status, rec.Code, tt.err.Error())
+	}
+}
+
+func TestMiddleware_ContextParser(t *testing.T) {
+	type traceKey struct{}
+	parser := contextParserFunc(func(ctx context.Context, authHeader string) (*User, error) {
+		return &User{AuthorizationValue: ctx.Value(traceKey{}).(string)}, nil
+	})
+
+	req, err := http.NewRequest("POST", "", nil)
+	assert.NoError(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), traceKey{}, "trace-id"))
+	req.Header.Add("Authorization", "bearer")
+	rec := httptest.NewRecorder()
+
+	nextHandler := &testNextHandler{}
+	Middleware(parser)(nextHandler.Handler()).ServeHTTP(rec, req)
+
+	user, _ := UserFromContext(nextHandler.req.Context())
+	assert.Equal(t, "trace-id", user.AuthorizationValue)
+}
+
+func TestAsContextParser(t *testing.T) {
+	calls := 0
+	legacy := &MockParser{ParseFn: func(authHeader string) (*User, error) {
+		calls++
+		return &User{}, nil
+	}}
+	cp := AsContextParser(legacy)
+
+	_, err := cp.ParseContext(context.Background(), "Bearer x")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cp.ParseContext(ctx, "Bearer x")
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 1, calls)
+
+	chain := NewChainParser()
+	assert.Same(t, chain, AsContextParser(chain))
+}
+
+type contextParserFunc func(ctx context.Context, authHeader string) (*User, error)
+
+func (f contextParserFunc) Parse(authHeader string) (*User, error) {
+	return f(context.Background(), authHeader)
+}
+
+func (f contextParserFunc) ParseContext(ctx context.Context, authHeader string) (*User, error) {
+	return f(ctx, authHeader)
+}
+
 func TestIsNotApiKey(t *testing.T) {
 	newUser := func(authHeader string) *User {
 		return &User{
@@ -182,6 +261,25 @@ func TestIsApiKey(t *testing.T) {
 	assert.Equal(t, true, isApiKey)
 }
 
+func TestIsApikeyFromContext_principal(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   *User
+		apikey bool
+	}{
+		{"service principal with email id", &User{UserID: []string{"svc@xtg.com"}, Principal: PrincipalService}, true},
+		{"user principal without email id", &User{UserID: []string{"auth0|1234"}, Principal: PrincipalUser}, false},
+		{"unknown principal without ids", &User{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := ContextWithUser(context.Background(), tt.user)
+			assert.Equal(t, tt.apikey, IsApikeyFromContext(ctx))
+		})
+	}
+	assert.False(t, IsApikeyFromContext(context.Background()))
+}
+
 func TestMiddleware_success(t *testing.T) {
 	newUser := func(authHeader string) *User {
 		return &User{AuthorizationValue: authHeader}
@@ -259,86 +357,38 @@ func TestTGXUser(t *testing.T) {
 
 	tgx_admin_service_admin := User{
 		TgxMember: true,
-		Orgs: []interface{}{
-			[]interface{}{
-				map[string]interface{}{
-					"o": "tgx",
-					"r": "ADMIN",
-					"s": []interface{}{map[string]interface{}{
-						"c": "ENTITIES",
-						"r": "ADMIN",
-					}},
-				},
-			},
+		Orgs: []Organization{
+			{Code: "tgx", Role: ADMIN, Services: []ServiceRole{{Service: ENTITIES, Role: ADMIN}}},
 		},
 	}
 
 	tgx_viewer_service_admin := User{
 		TgxMember: true,
-		Orgs: []interface{}{
-			[]interface{}{
-				map[string]interface{}{
-					"o": "tgx",
-					"r": "VIEWER",
-					"s": []interface{}{map[string]interface{}{
-						"c": "ENTITIES",
-						"r": "ADMIN",
-					}},
-				},
-			},
+		Orgs: []Organization{
+			{Code: "tgx", Role: VIEWER, Services: []ServiceRole{{Service: ENTITIES, Role: ADMIN}}},
 		},
 	}
 
 	tgx_viewer_service_viewer := User{
 		TgxMember: true,
-		Orgs: []interface{}{
-			[]interface{}{
-				map[string]interface{}{
-					"o": "tgx",
-					"r": "VIEWER",
-					"s": []interface{}{map[string]interface{}{
-						"c": "ENTITIES",
-						"r": "VIEWER",
-					}},
-				},
-			},
+		Orgs: []Organization{
+			{Code: "tgx", Role: VIEWER, Services: []ServiceRole{{Service: ENTITIES, Role: VIEWER}}},
 		},
 	}
 
 	tgx_viewer := User{
 		TgxMember: true,
-		Orgs: []interface{}{
-			[]interface{}{
-				map[string]interface{}{
-					"o": "tgx",
-					"r": "VIEWER",
-				},
-			},
-		},
+		Orgs:      []Organization{{Code: "tgx", Role: VIEWER}},
 	}
 
 	tgx_admin := User{
 		TgxMember: true,
-		Orgs: []interface{}{
-			[]interface{}{
-				map[string]interface{}{
-					"o": "tgx",
-					"r": "ADMIN",
-				},
-			},
-		},
+		Orgs:      []Organization{{Code: "tgx", Role: ADMIN}},
 	}
 
 	no_tgx_admin := User{
 		TgxMember: false,
-		Orgs: []interface{}{
-			[]interface{}{
-				map[string]interface{}{
-					"o": "org1",
-					"r": "ADMIN",
-				},
-			},
-		},
+		Orgs:      []Organization{{Code: "org1", Role: ADMIN}},
 	}
 
 	if no_tgx_admin.IsTGXMemberRole(ADMIN, &entitiesService) {
@@ -366,44 +416,13 @@ func TestGetOrgs(t *testing.T) {
 	entitiesService := ENTITIES
 
 	user := User{
-		Orgs: []interface{}{
-			[]interface{}{
-				map[string]interface{}{
-					"o": "org1",
-					"r": "OWNER",
-					"s": []interface{}{map[string]interface{}{
-						"c": "ENTITIES",
-						"r": "ADMIN",
-					}},
-				},
-				map[string]interface{}{
-					"o": "org2",
-					"r": "ADMIN",
-				},
-				map[string]interface{}{
-					"o": "org3",
-					"r": "EDITOR",
-				},
-				map[string]interface{}{
-					"o": "org4",
-				},
-				map[string]interface{}{
-					"o": "org5",
-					"r": "VIEWER",
-					"s": []interface{}{map[string]interface{}{
-						"c": "ENTITIES",
-						"r": "ADMIN",
-					}},
-				},
-				map[string]interface{}{
-					"o": "org6",
-					"r": "VIEWER",
-					"s": []interface{}{map[string]interface{}{
-						"c": "ENTITIES",
-						"r": "EDITOR",
-					}},
-				},
-			},
+		Orgs: []Organization{
+			{Code: "org1", Role: OWNER, Services: []ServiceRole{{Service: ENTITIES, Role: ADMIN}}},
+			{Code: "org2", Role: ADMIN},
+			{Code: "org3", Role: EDITOR},
+			{Code: "org4"},
+			{Code: "org5", Role: VIEWER, Services: []ServiceRole{{Service: ENTITIES, Role: ADMIN}}},
+			{Code: "org6", Role: VIEWER, Services: []ServiceRole{{Service: ENTITIES, Role: EDITOR}}},
 		},
 	}
 
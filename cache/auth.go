@@ -1,29 +1,54 @@
 package cache
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	authorization "github.com/travelgateX/go-jwt-tools"
 
 	"github.com/travelgateX/go-cache/cache"
 )
 
+var _ authorization.ContextParser = (*Parser)(nil)
+
 type Parser struct {
 	p authorization.Parser
 	c *cache.FetcherLRU
+	r authorization.Revoker
 }
 
 func NewParser(p authorization.Parser, c *cache.FetcherLRU) authorization.Parser {
-	return &Parser{p, c}
+	return &Parser{p: p, c: c}
+}
+
+// NewParserWithRevoker returns a cache Parser that checks every User, cached or not, against r.
+// Revoked users are evicted from the cache
+func NewParserWithRevoker(p authorization.Parser, c *cache.FetcherLRU, r authorization.Revoker) authorization.Parser {
+	return &Parser{p: p, c: c, r: r}
 }
 
 func (p *Parser) Parse(authorizationHeader string) (*authorization.User, error) {
+	return p.ParseContext(context.Background(), authorizationHeader)
+}
+
+// ParseContext parses with the values of the context of the call that misses the cache. The parse
+// is shared with the concurrent calls for the same header and refreshes expired entries in the
+// background, after the call returned, so it doesn't end with the cancellation of that context.
+// An entry whose refresh rejects the credentials is evicted
+func (p *Parser) ParseContext(ctx context.Context, authorizationHeader string) (*authorization.User, error) {
+	detached := context.WithoutCancel(ctx)
 	onFetch := func() (interface{}, error) {
-		user, err := p.p.Parse(authorizationHeader)
+		user, err := authorization.AsContextParser(p.p).ParseContext(detached, authorizationHeader)
 		if err != nil {
-			if err == authorization.ErrInvalidUser {
+			if errors.Is(err, authorization.ErrUnauthenticated) && !errors.Is(err, authorization.ErrUnavailable) {
 				p.c.Remove(authorizationHeader)
 			}
 			return nil, err
 		}
+		if user == nil {
+			return nil, nil
+		}
 		return user, nil
 	}
 	v, err := p.c.GetOrFetch(authorizationHeader, onFetch)
@@ -31,7 +56,26 @@ func (p *Parser) Parse(authorizationHeader string) (*authorization.User, error)
 		return nil, err
 	}
 	if v == nil {
-		return nil, nil
+		return nil, ErrEmptyEntry
+	}
+	user := v.(*authorization.User)
+	if err := p.checkRevoked(authorizationHeader, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (p *Parser) checkRevoked(authorizationHeader string, user *authorization.User) error {
+	if p.r == nil {
+		return nil
+	}
+	revoked, err := authorization.IsRevoked(p.r, user)
+	if err != nil {
+		return fmt.Errorf("error checking revocation: %w", err)
+	}
+	if revoked {
+		p.c.Remove(authorizationHeader)
+		return authorization.ErrRevoked
 	}
-	return v.(*authorization.User), nil
+	return nil
 }
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jwtgo "github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+	"github.com/travelgateX/go-jwt-tools/jwt"
+	"github.com/travelgateX/go-jwt-tools/revocation"
+
+	"github.com/travelgateX/go-cache/cache"
+)
+
+func TestParser_revokedUserIsEvicted(t *testing.T) {
+	calls := 0
+	parser := &authorization.MockParser{
+		ParseFn: func(authHeader string) (*authorization.User, error) {
+			calls++
+			return &authorization.User{AuthorizationValue: authHeader, TokenID: "t1", UserID: []string{"a@xtg.com"}}, nil
+		},
+	}
+	c, err := cache.New(10, time.Minute)
+	require.NoError(t, err)
+	r := revocation.NewMemoryRevoker()
+	p := NewParserWithRevoker(parser, c, r)
+
+	_, err = p.Parse("Bearer t1")
+	require.NoError(t, err)
+	_, err = p.Parse("Bearer t1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	r.RevokeToken("t1")
+	_, err = p.Parse("Bearer t1")
+	assert.True(t, errors.Is(err, authorization.ErrRevoked))
+	assert.True(t, errors.Is(err, authorization.ErrUnauthenticated))
+
+	// the entry was evicted, the next call reaches the wrapped parser again
+	_, err = p.Parse("Bearer t1")
+	assert.True(t, errors.Is(err, authorization.ErrRevoked))
+	assert.Equal(t, 2, calls)
+}
+
+type fetcherFunc func(ctx context.Context, shortToken string) (string, error)
+
+func (f fetcherFunc) FetchBearer(ctx context.Context, shortToken string) (string, error) {
+	return f(ctx, shortToken)
+}
+
+func TestParser_revokedShortTokenIsEvicted(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	sign := func(claims jwtgo.MapClaims) string {
+		s, err := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, claims).SignedString(key)
+		require.NoError(t, err)
+		return s
+	}
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	iat := float64(time.Now().Add(-time.Minute).Unix())
+	full := sign(jwtgo.MapClaims{"exp": exp, "iat": iat, "jti": "full", "https://xtg.com/member_id": "a@xtg.com"})
+	short := sign(jwtgo.MapClaims{"exp": exp, "iat": iat, "jti": "short", "https://xtg.com/member_id": "a@xtg.com", "https://xtg.com/fetch_needed": true})
+
+	parser, err := jwt.NewParserE(jwt.ParserConfig{
+		PublicKey:        string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		MemberIDClaim:    []string{"https://xtg.com/member_id"},
+		FetchNeededClaim: []string{"https://xtg.com/fetch_needed"},
+		Fetcher: fetcherFunc(func(ctx context.Context, shortToken string) (string, error) {
+			return full, nil
+		}),
+	})
+	require.NoError(t, err)
+	c, err := cache.New(10, time.Minute)
+	require.NoError(t, err)
+	r := revocation.NewMemoryRevoker()
+	p := NewParserWithRevoker(parser, c, r)
+
+	u, err := p.Parse("Bearer " + short)
+	require.NoError(t, err)
+	assert.Equal(t, "full", u.TokenID)
+	assert.Equal(t, "short", u.ShortTokenID)
+	assert.Equal(t, iat, u.ShortIssuedAt)
+
+	// the cached User was built from the full token, revoking the short one is noticed
+	r.RevokeToken("short")
+	_, err = p.Parse("Bearer " + short)
+	assert.True(t, errors.Is(err, authorization.ErrRevoked))
+}
+
+func TestParser_emptyEntry(t *testing.T) {
+	parser := &authorization.MockParser{
+		ParseFn: func(authHeader string) (*authorization.User, error) {
+			return nil, nil
+		},
+	}
+	c, err := cache.New(10, time.Minute)
+	require.NoError(t, err)
+
+	_, err = NewParser(parser, c).Parse("Bearer x")
+	assert.True(t, errors.Is(err, ErrEmptyEntry))
+}
+
+type contextParserFunc func(ctx context.Context, authHeader string) (*authorization.User, error)
+
+func (f contextParserFunc) Parse(authHeader string) (*authorization.User, error) {
+	return f(context.Background(), authHeader)
+}
+
+func (f contextParserFunc) ParseContext(ctx context.Context, authHeader string) (*authorization.User, error) {
+	return f(ctx, authHeader)
+}
+
+func TestParser_staleRefreshOutlivesRequest(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	refreshed := make(chan error, 1)
+	parser := contextParserFunc(func(ctx context.Context, authHeader string) (*authorization.User, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return &authorization.User{AuthorizationValue: authHeader}, nil
+		}
+		// the refresh runs after the request that triggered it returned
+		<-release
+		if err := ctx.Err(); err != nil {
+			refreshed <- err
+			return nil, err
+		}
+		refreshed <- nil
+		return nil, authorization.ErrInvalidUser
+	})
+	c, err := cache.New(10, 10*time.Millisecond)
+	require.NoError(t, err)
+	p := NewParser(parser, c).(*Parser)
+
+	_, err = p.Parse("Bearer t1")
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	// the expired entry is served while it's refreshed
+	ctx, cancel := context.WithCancel(context.Background())
+	u, err := p.ParseContext(ctx, "Bearer t1")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer t1", u.AuthorizationValue)
+	cancel()
+	close(release)
+	require.NoError(t, <-refreshed)
+
+	// the refresh rejected the token, which is no longer served from the cache
+	require.Eventually(t, func() bool {
+		_, err := p.Parse("Bearer t1")
+		return errors.Is(err, authorization.ErrInvalidUser)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestParser_sharedParseOutlivesFirstCaller(t *testing.T) {
+	release := make(chan struct{})
+	parser := contextParserFunc(func(ctx context.Context, authHeader string) (*authorization.User, error) {
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return &authorization.User{AuthorizationValue: authHeader}, nil
+	})
+	c, err := cache.New(10, time.Minute)
+	require.NoError(t, err)
+	p := NewParser(parser, c).(*Parser)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := p.ParseContext(ctx, "Bearer t1")
+		first <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	close(release)
+	assert.NoError(t, <-first)
+
+	u, err := p.Parse("Bearer t1")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer t1", u.AuthorizationValue)
+}
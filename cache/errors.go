@@ -0,0 +1,8 @@
+package cache
+
+import (
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+// ErrEmptyEntry is returned when the cached value for an Authorization header holds no User
+var ErrEmptyEntry = authorization.NewError("cache: empty user entry", authorization.ErrUnauthenticated)
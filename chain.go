@@ -0,0 +1,105 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	_ Parser        = (*ChainParser)(nil)
+	_ ContextParser = (*ChainParser)(nil)
+)
+
+// ErrUnsupportedScheme is returned by ChainParser when no Parser accepts the Authorization scheme
+var ErrUnsupportedScheme error = NewError("unsupported authorization scheme", ErrMalformedHeader)
+
+// SchemeParser associates a Parser to an Authorization scheme, e.g. "Bearer". Schemes are matched case
+// insensitively and an empty Scheme matches any scheme
+type SchemeParser struct {
+	Scheme string
+	Parser Parser
+}
+
+// ChainParser accepts several authentication schemes by dispatching each Authorization header to the
+// parsers registered for its scheme, in registration order
+type ChainParser struct {
+	parsers []SchemeParser
+	// FallThrough makes a parser that doesn't recognize the header, failing with ErrMalformedHeader,
+	// pass it to the next parser accepting the scheme. Any other failure decides, as does the first
+	// parser accepting the scheme without FallThrough
+	FallThrough bool
+}
+
+// NewChainParser returns a ChainParser for the given parsers
+func NewChainParser(parsers ...SchemeParser) *ChainParser {
+	return &ChainParser{parsers: parsers}
+}
+
+func (c *ChainParser) Parse(authHeader string) (*User, error) {
+	return c.ParseContext(context.Background(), authHeader)
+}
+
+func (c *ChainParser) ParseContext(ctx context.Context, authHeader string) (*User, error) {
+	scheme, _, _ := strings.Cut(authHeader, " ")
+
+	var attempts []*SchemeError
+	for i, sp := range c.parsers {
+		if sp.Scheme != "" && !strings.EqualFold(sp.Scheme, scheme) {
+			continue
+		}
+		u, err := AsContextParser(sp.Parser).ParseContext(ctx, authHeader)
+		if err == nil {
+			return u, nil
+		}
+		attempts = append(attempts, &SchemeError{Scheme: scheme, Index: i, Err: err})
+		if !c.FallThrough || !errors.Is(err, ErrMalformedHeader) {
+			break
+		}
+	}
+
+	if len(attempts) == 0 {
+		return nil, ErrUnsupportedScheme
+	}
+	return nil, &ChainError{Attempts: attempts}
+}
+
+// SchemeError is the error of one of the parsers attempted by a ChainParser
+type SchemeError struct {
+	// Scheme of the Authorization header
+	Scheme string
+	// Index of the parser in the ChainParser
+	Index int
+	Err   error
+}
+
+func (e *SchemeError) Error() string {
+	return "scheme " + e.Scheme + ": " + e.Err.Error()
+}
+
+func (e *SchemeError) Unwrap() error {
+	return e.Err
+}
+
+// ChainError aggregates the errors of every parser attempted by a ChainParser. The last attempt is the
+// decisive one, errors.Is and errors.As only match its error, so the class of the failure is its class
+type ChainError struct {
+	Attempts []*SchemeError
+}
+
+func (e *ChainError) Error() string {
+	msgs := make([]string, len(e.Attempts))
+	for i, a := range e.Attempts {
+		msgs[i] = a.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ChainError) Unwrap() error {
+	return e.Attempts[len(e.Attempts)-1]
+}
+
+// Last returns the error of the last attempted parser
+func (e *ChainError) Last() error {
+	return e.Attempts[len(e.Attempts)-1].Err
+}
@@ -0,0 +1,100 @@
+package authorization
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainParser(t *testing.T) {
+	errNotJWT := NewError("not a jwt", ErrMalformedHeader)
+	errJWT := NewError("expired jwt", ErrUnauthenticated)
+	errDev := NewError("unknown dev token", ErrUnauthenticated)
+
+	jwtParser := &MockParser{ParseFn: func(authHeader string) (*User, error) {
+		switch authHeader {
+		case "Bearer jwt":
+			return &User{UserID: []string{"jwt"}}, nil
+		case "Bearer expired":
+			return nil, errJWT
+		default:
+			return nil, errNotJWT
+		}
+	}}
+	devParser := &MockParser{ParseFn: func(authHeader string) (*User, error) {
+		if authHeader == "Bearer dev" {
+			return &User{UserID: []string{"dev"}}, nil
+		}
+		return nil, errDev
+	}}
+	apikeyParser := &MockParser{ParseFn: func(authHeader string) (*User, error) {
+		return &User{UserID: []string{"apikey"}}, nil
+	}}
+
+	chain := NewChainParser(
+		SchemeParser{Scheme: "Bearer", Parser: jwtParser},
+		SchemeParser{Scheme: "Bearer", Parser: devParser},
+		SchemeParser{Scheme: "Apikey", Parser: apikeyParser},
+	)
+
+	u, err := chain.Parse("Bearer jwt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jwt"}, u.UserID)
+
+	u, err = chain.Parse("apikey abc")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"apikey"}, u.UserID)
+
+	// without fall through only the first Bearer parser is attempted
+	_, err = chain.Parse("Bearer dev")
+	assert.True(t, errors.Is(err, errNotJWT))
+	assert.False(t, errors.Is(err, errDev))
+
+	// a header the jwt parser doesn't recognize falls through
+	chain.FallThrough = true
+	u, err = chain.Parse("Bearer dev")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev"}, u.UserID)
+
+	// the class is the one of the decisive attempt, not of the header the jwt parser didn't recognize
+	_, err = chain.Parse("Bearer other")
+	var chainErr *ChainError
+	require.True(t, errors.As(err, &chainErr))
+	require.Len(t, chainErr.Attempts, 2)
+	assert.Equal(t, 0, chainErr.Attempts[0].Index)
+	assert.Equal(t, 1, chainErr.Attempts[1].Index)
+	assert.Equal(t, "Bearer", chainErr.Attempts[1].Scheme)
+	assert.True(t, errors.Is(err, errDev))
+	assert.False(t, errors.Is(err, ErrMalformedHeader))
+	assert.Equal(t, http.StatusUnauthorized, statusCode(err))
+	assert.Equal(t, errDev, chainErr.Last())
+	assert.Equal(t, "scheme Bearer: not a jwt; scheme Bearer: unknown dev token", err.Error())
+
+	// a token the jwt parser recognizes but rejects decides
+	_, err = chain.Parse("Bearer expired")
+	require.True(t, errors.As(err, &chainErr))
+	require.Len(t, chainErr.Attempts, 1)
+	assert.True(t, errors.Is(err, errJWT))
+
+	_, err = chain.Parse("Basic abc")
+	assert.True(t, errors.Is(err, ErrUnsupportedScheme))
+	assert.True(t, errors.Is(err, ErrMalformedHeader))
+}
+
+func TestChainParser_anyScheme(t *testing.T) {
+	unavailable := NewError("down", ErrUnavailable)
+	chain := NewChainParser(
+		SchemeParser{Scheme: "Bearer", Parser: &MockParser{ParseFn: func(string) (*User, error) { return nil, unavailable }}},
+		SchemeParser{Parser: &MockParser{ParseFn: func(h string) (*User, error) { return &User{AuthorizationValue: h}, nil }}},
+	)
+
+	_, err := chain.Parse("Bearer x")
+	assert.True(t, errors.Is(err, ErrUnavailable))
+
+	u, err := chain.Parse("Custom x")
+	require.NoError(t, err)
+	assert.Equal(t, "Custom x", u.AuthorizationValue)
+}
@@ -2,6 +2,49 @@ package authorization
 
 import (
 	"errors"
+	"net/http"
 )
 
-var ErrInvalidUser = errors.New("invalid User")
+// Error classes, every error returned by the parsers of this module matches one of them with errors.Is
+var (
+	// ErrMalformedHeader is the class of errors caused by an Authorization header the parser can't read
+	ErrMalformedHeader = errors.New("malformed authorization header")
+	// ErrUnauthenticated is the class of errors caused by invalid, expired or rejected credentials
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrUnavailable is the class of errors caused by a dependency of the parser, the credentials
+	// may be valid but can't be checked right now
+	ErrUnavailable = errors.New("authorization unavailable")
+)
+
+var ErrInvalidUser error = NewError("invalid User", ErrUnauthenticated)
+
+// Error is an error of a given class
+type Error struct {
+	Msg   string
+	Class error
+}
+
+// NewError returns an error with message msg for which errors.Is(err, class) holds
+func NewError(msg string, class error) *Error {
+	return &Error{Msg: msg, Class: class}
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Class
+}
+
+// statusCode returns the http status Middleware responds with for a Parser error
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrMalformedHeader):
+		return http.StatusBadRequest
+	default:
+		return http.StatusUnauthorized
+	}
+}
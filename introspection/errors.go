@@ -0,0 +1,12 @@
+package introspection
+
+import (
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+var (
+	// ErrInactiveToken is returned when the endpoint reports the token as not active
+	ErrInactiveToken = authorization.NewError("token is not active", authorization.ErrUnauthenticated)
+	// ErrEndpointUnavailable is returned when the endpoint can't be reached or answers with an error
+	ErrEndpointUnavailable = authorization.NewError("introspection endpoint unavailable", authorization.ErrUnavailable)
+)
@@ -0,0 +1,143 @@
+// Package introspection implements an authorization.Parser for opaque access tokens, validated by an
+// OAuth 2.0 Token Introspection endpoint (RFC 7662)
+package introspection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+	"github.com/travelgateX/go-jwt-tools/jwt"
+)
+
+var (
+	_ authorization.Parser        = (*Parser)(nil)
+	_ authorization.ContextParser = (*Parser)(nil)
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// maxResponseSize bounds the introspection response read into memory
+	maxResponseSize = 1 << 20
+)
+
+// Config is the data required to instance a Parser
+type Config struct {
+	// URL of the introspection endpoint
+	URL string `json:"url"`
+	// ClientID and ClientSecret authenticate the resource server with HTTP Basic auth, if set
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// Timeout of the introspection request. Defaults to 10s
+	Timeout time.Duration `json:"timeout"`
+	// Claims names the claims of the introspection response the User is built from, the same way as for
+	// the jwt Parser. Only the claim names, AdminGroup, ServicePrincipal, Issuers and Audiences are used. MemberIDClaim
+	// defaults to sub
+	Claims jwt.ParserConfig `json:"claims"`
+}
+
+// Parser creates a User from a Bearer by asking an introspection endpoint for its claims
+type Parser struct {
+	config Config
+	client *http.Client
+	// Now is the clock used to set User.IsExpired. Defaults to time.Now
+	Now func() time.Time
+}
+
+// NewParser returns an instance of Parser
+func NewParser(c Config) *Parser {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if len(c.Claims.MemberIDClaim) == 0 {
+		c.Claims.MemberIDClaim = []string{"sub"}
+	}
+	// the endpoint is the authority on expiration and there is no full token to fetch
+	c.Claims.IgnoreExpiration = true
+	c.Claims.DisableFetchNeeded = true
+
+	return &Parser{
+		config: c,
+		client: &http.Client{Timeout: c.Timeout},
+	}
+}
+
+func (p *Parser) Parse(authorizationHeader string) (*authorization.User, error) {
+	return p.ParseContext(context.Background(), authorizationHeader)
+}
+
+// ParseContext parses the header, ctx bounds the introspection request
+func (p *Parser) ParseContext(ctx context.Context, authorizationHeader string) (*authorization.User, error) {
+	scheme, token, ok := strings.Cut(authorizationHeader, " ")
+	if !ok || scheme != "Bearer" || token == "" {
+		return nil, jwt.ErrMalformedHeader
+	}
+
+	response, err := p.introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if active, _ := response["active"].(bool); !active {
+		return nil, ErrInactiveToken
+	}
+
+	claims, err := jwt.ParseClaims(response, p.config.Claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.config.Claims.ValidateIssuerAndAudience(claims); err != nil {
+		return nil, err
+	}
+
+	user := claims.User(authorizationHeader, p.config.Claims.AdminGroup)
+	user.Principal = p.config.Claims.UserPrincipal()
+	user.IsExpired = !claims.ExpiresAt.IsZero() && claims.ExpiresAt.Before(p.now())
+	return user, nil
+}
+
+// introspect posts the token to the endpoint and returns the decoded response
+func (p *Parser) introspect(ctx context.Context, token string) (map[string]interface{}, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.config.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEndpointUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEndpointUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEndpointUnavailable, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %s", ErrEndpointUnavailable, res.Status)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("%w: decoding response: %v", ErrEndpointUnavailable, err)
+	}
+	return response, nil
+}
+
+func (p *Parser) now() time.Time {
+	if p.Now == nil {
+		return time.Now()
+	}
+	return p.Now()
+}
@@ -0,0 +1,142 @@
+package introspection
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+	authcache "github.com/travelgateX/go-jwt-tools/cache"
+	"github.com/travelgateX/go-jwt-tools/jwt"
+
+	"github.com/travelgateX/go-cache/cache"
+)
+
+// newIntrospectionServer answers with the response registered for each token, or inactive
+func newIntrospectionServer(t *testing.T, responses map[string]map[string]interface{}, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "rs" || secret != "s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "access_token", r.PostFormValue("token_type_hint"))
+
+		response, ok := responses[r.PostFormValue("token")]
+		if !ok {
+			response = map[string]interface{}{"active": false}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+func testConfig(url string) Config {
+	return Config{
+		URL:          url,
+		ClientID:     "rs",
+		ClientSecret: "s3cret",
+		Claims: jwt.ParserConfig{
+			TGXMemberClaim:     []string{"https://xtg.com/is_tgx"},
+			OrganizationsClaim: []string{"https://xtg.com/org"},
+		},
+	}
+}
+
+func TestParser(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	var calls int32
+	srv := newIntrospectionServer(t, map[string]map[string]interface{}{
+		"opaque": {
+			"active":                 true,
+			"sub":                    "user@xtg.com",
+			"scope":                  "read write",
+			"exp":                    exp,
+			"jti":                    "t1",
+			"https://xtg.com/is_tgx": true,
+			"https://xtg.com/org":    []interface{}{map[string]interface{}{"o": "org1", "r": "ADMIN"}},
+		},
+	}, &calls)
+	defer srv.Close()
+
+	u, err := NewParser(testConfig(srv.URL)).Parse("Bearer opaque")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer opaque", u.AuthorizationValue)
+	assert.Equal(t, []string{"user@xtg.com"}, u.UserID)
+	assert.Equal(t, []string{"read", "write"}, u.Scopes)
+	assert.EqualValues(t, exp, u.Expiration)
+	assert.False(t, u.IsExpired)
+	assert.Equal(t, "t1", u.TokenID)
+	assert.True(t, u.TgxMember)
+	assert.Equal(t, []string{"org1"}, u.GetOrgs(authorization.ADMIN))
+	assert.NotNil(t, u.Permissions)
+	assert.Equal(t, authorization.PrincipalUser, u.Principal)
+
+	c := testConfig(srv.URL)
+	c.Claims.ServicePrincipal = true
+	u, err = NewParser(c).Parse("Bearer opaque")
+	require.NoError(t, err)
+	assert.Equal(t, authorization.PrincipalService, u.Principal)
+}
+
+func TestParser_errors(t *testing.T) {
+	var calls int32
+	srv := newIntrospectionServer(t, map[string]map[string]interface{}{
+		"no-sub":     {"active": true},
+		"bad-member": {"active": true, "sub": 1},
+		"other-aud":  {"active": true, "sub": "user@xtg.com", "iss": "https://iam.xtg.com/", "aud": "billing"},
+	}, &calls)
+	defer srv.Close()
+	p := NewParser(testConfig(srv.URL))
+
+	_, err := p.Parse("Basic abc")
+	assert.True(t, errors.Is(err, authorization.ErrMalformedHeader))
+
+	_, err = p.Parse("Bearer revoked")
+	assert.True(t, errors.Is(err, ErrInactiveToken))
+	assert.True(t, errors.Is(err, authorization.ErrUnauthenticated))
+
+	_, err = p.Parse("Bearer no-sub")
+	assert.True(t, errors.Is(err, jwt.ErrMissingClaim))
+
+	_, err = p.Parse("Bearer bad-member")
+	assert.True(t, errors.Is(err, jwt.ErrInvalidClaim))
+
+	c := testConfig(srv.URL)
+	c.Claims.Issuers = []string{"https://iam.xtg.com/"}
+	c.Claims.Audiences = []string{"hotelx"}
+	_, err = NewParser(c).Parse("Bearer other-aud")
+	assert.True(t, errors.Is(err, jwt.ErrInvalidAudience))
+
+	c = testConfig(srv.URL)
+	c.ClientSecret = "wrong"
+	_, err = NewParser(c).Parse("Bearer opaque")
+	assert.True(t, errors.Is(err, ErrEndpointUnavailable))
+	assert.True(t, errors.Is(err, authorization.ErrUnavailable))
+}
+
+func TestParser_cached(t *testing.T) {
+	var calls int32
+	srv := newIntrospectionServer(t, map[string]map[string]interface{}{
+		"opaque": {"active": true, "sub": "user@xtg.com"},
+	}, &calls)
+	defer srv.Close()
+
+	c, err := cache.New(10, time.Minute)
+	require.NoError(t, err)
+	p := authcache.NewParser(NewParser(testConfig(srv.URL)), c)
+
+	for i := 0; i < 3; i++ {
+		u, err := p.Parse("Bearer opaque")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user@xtg.com"}, u.UserID)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,108 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+
+	"github.com/form3tech-oss/jwt-go"
+)
+
+// DefaultAlgorithm is the only algorithm accepted when ParserConfig.AllowedAlgorithms is empty
+const DefaultAlgorithm = "RS256"
+
+// supportedAlgorithms maps every accepted alg to a check of the verification key it requires.
+// Symmetric algorithms and "none" are deliberately absent: a public key can never verify them
+var supportedAlgorithms = map[string]func(key interface{}) bool{
+	"RS256": isRSAKey,
+	"RS384": isRSAKey,
+	"RS512": isRSAKey,
+	"PS256": isRSAKey,
+	"PS384": isRSAKey,
+	"PS512": isRSAKey,
+	"ES256": isECKey(elliptic.P256()),
+	"ES384": isECKey(elliptic.P384()),
+	"ES512": isECKey(elliptic.P521()),
+	"EdDSA": isEdKey,
+}
+
+func isRSAKey(key interface{}) bool {
+	_, ok := key.(*rsa.PublicKey)
+	return ok
+}
+
+func isECKey(curve elliptic.Curve) func(key interface{}) bool {
+	return func(key interface{}) bool {
+		k, ok := key.(*ecdsa.PublicKey)
+		return ok && k.Curve == curve
+	}
+}
+
+func isEdKey(key interface{}) bool {
+	_, ok := key.(ed25519.PublicKey)
+	return ok
+}
+
+// allowedAlgorithms returns the configured algorithms that this package is able to verify
+func (p ParserConfig) allowedAlgorithms() []string {
+	if len(p.AllowedAlgorithms) == 0 {
+		return []string{DefaultAlgorithm}
+	}
+	algs := make([]string, 0, len(p.AllowedAlgorithms))
+	for _, alg := range p.AllowedAlgorithms {
+		if _, ok := supportedAlgorithms[alg]; ok {
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// algorithmKeyFunc wraps a key resolver so that a key is only handed to the library when the token alg
+// is allowed and the resolved key is of the type that alg requires
+func algorithmKeyFunc(allowed []string, resolve jwt.Keyfunc) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		alg, _ := token.Header["alg"].(string)
+		if token.Method == nil || token.Method.Alg() != alg {
+			return nil, fmt.Errorf("%w: unexpected signing method %q", ErrUnsupportedAlgorithm, alg)
+		}
+		if !authorization.Contains(allowed, alg) {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+		}
+
+		key, err := resolve(token)
+		if err != nil {
+			return nil, err
+		}
+		if !supportedAlgorithms[alg](key) {
+			return nil, fmt.Errorf("%w: %q can't be verified with a %T key", ErrUnsupportedAlgorithm, alg, key)
+		}
+		return key, nil
+	}
+}
+
+// parsePublicKeyPEM decodes a PKIX or PKCS1 public key, or the public key of a x509 certificate
+func parsePublicKeyPEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("public key must be PEM encoded")
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
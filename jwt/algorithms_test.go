@@ -0,0 +1,111 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_AllowedAlgorithms(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	ec256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ec384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		method  jwt.SigningMethod
+		private interface{}
+		public  interface{}
+	}{
+		{jwt.SigningMethodRS256, rsaKey, &rsaKey.PublicKey},
+		{jwt.SigningMethodRS384, rsaKey, &rsaKey.PublicKey},
+		{jwt.SigningMethodRS512, rsaKey, &rsaKey.PublicKey},
+		{jwt.SigningMethodPS256, rsaKey, &rsaKey.PublicKey},
+		{jwt.SigningMethodES256, ec256, &ec256.PublicKey},
+		{jwt.SigningMethodES384, ec384, &ec384.PublicKey},
+		{SigningMethodEd25519, edPriv, edPub},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method.Alg(), func(t *testing.T) {
+			bearer := "Bearer " + signToken(t, tt.method, tt.private, "", testClaims())
+
+			c := testParserConfig()
+			c.PublicKey = publicKeyPEM(t, tt.public)
+			c.AllowedAlgorithms = []string{tt.method.Alg()}
+			_, err := NewParser(c).Parse(bearer)
+			assert.NoError(t, err)
+
+			c.AllowedAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+			c.AllowedAlgorithms = removeString(c.AllowedAlgorithms, tt.method.Alg())
+			_, err = NewParser(c).Parse(bearer)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParser_defaultAlgorithm(t *testing.T) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	p := NewParser(c)
+
+	_, err := p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, key, "", testClaims()))
+	assert.NoError(t, err)
+	_, err = p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS512, key, "", testClaims()))
+	assert.Error(t, err)
+}
+
+func TestParser_rejectsNone(t *testing.T) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	c.AllowedAlgorithms = []string{"none", "RS256"}
+
+	bearer := "Bearer " + signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", testClaims())
+	_, err := NewParser(c).Parse(bearer)
+	assert.Error(t, err)
+}
+
+func TestParser_rejectsHMACKeyConfusion(t *testing.T) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	c.AllowedAlgorithms = []string{"HS256", "RS256"}
+
+	// the public key is known to anyone, signing with it as an HMAC secret must not be accepted
+	bearer := "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(c.PublicKey), "", testClaims())
+	_, err := NewParser(c).Parse(bearer)
+	assert.Error(t, err)
+}
+
+func TestParser_rejectsMismatchedKeyType(t *testing.T) {
+	ec256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &ec256.PublicKey)
+	c.AllowedAlgorithms = []string{"ES384"}
+
+	bearer := "Bearer " + signToken(t, jwt.SigningMethodES256, ec256, "", testClaims())
+	_, err = NewParser(c).Parse(bearer)
+	assert.Error(t, err)
+}
+
+func removeString(a []string, e string) []string {
+	out := []string{}
+	for _, x := range a {
+		if x != e {
+			out = append(out, x)
+		}
+	}
+	return out
+}
@@ -1,7 +1,9 @@
 package jwt
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -10,173 +12,314 @@ import (
 	"github.com/form3tech-oss/jwt-go"
 )
 
-var _ authorization.Parser = (*Parser)(nil)
+var (
+	_ authorization.Parser        = (*Parser)(nil)
+	_ authorization.ContextParser = (*Parser)(nil)
+)
 
 type Parser struct {
-	client  client
+	// Fetcher fetches the full token of fetch needed tokens, it is built from ParserConfig.Fetcher and
+	// ClientConfig. Fetch needed tokens are parsed as full tokens when it is nil
+	Fetcher BearerFetcher
+	bearers *bearerCache
 	KeyFunc func(token *jwt.Token) (interface{}, error)
+	// Now is the clock used to validate exp, nbf and iat. Defaults to time.Now
+	Now func() time.Time
+	// Revoker, when set, is consulted for every parsed token
+	Revoker    authorization.Revoker
+	algorithms []string
 	ParserConfig
 }
 
-// ParserConfig is the data required to instance a Parser
+// ParserConfig is the data required to instance a Parser. Expiration and IsExpired are kept for
+// compatibility but have no effect, Validate reports them when set
 type ParserConfig struct {
-	ClientConfig       *ClientConfig `json:"client_config"`
-	PublicKey          string        `json:"public_key_str"`
-	AdminGroup         string        `json:"admin_group"`
-	DummyToken         string        `json:"dummy_token"`
-	Expiration         string        `json:"exp"`
-	IsExpired          bool          `json:"is_expired"`
-	MemberIDClaim      []string      `json:"member_id_claim"`
-	GroupsClaim        []string      `json:"groups_claim"`
-	FetchNeededClaim   []string      `json:"fetch_needed_claim"`
-	TGXMemberClaim     []string      `json:"tgx_member_claim"`
-	OrganizationsClaim []string      `json:"organizations_claim"`
-	IgnoreExpiration   bool          `json:"ignore_expiration"`
-	DisableFetchNeeded bool          `json:"disable_fetch_needed"`
+	ClientConfig       *ClientConfig        `json:"client_config"`
+	PublicKey          string               `json:"public_key_str"`
+	PublicKeyFile      string               `json:"public_key_file"`
+	JWKS               *JWKSConfig          `json:"jwks"`
+	AllowedAlgorithms  []string             `json:"allowed_algorithms"`
+	Issuers            []string             `json:"issuers"`
+	Audiences          []string             `json:"audiences"`
+	Leeway             time.Duration        `json:"leeway"`
+	AdminGroup         string               `json:"admin_group"`
+	DummyToken         string               `json:"dummy_token"`
+	DummyUsers         map[string]DummyUser `json:"dummy_users"`
+	EnableDummyTokens  bool                 `json:"enable_dummy_tokens"`
+	Expiration         string               `json:"exp"`
+	IsExpired          bool                 `json:"is_expired"`
+	MemberIDClaim      []string             `json:"member_id_claim"`
+	GroupsClaim        []string             `json:"groups_claim"`
+	FetchNeededClaim   []string             `json:"fetch_needed_claim"`
+	TGXMemberClaim     []string             `json:"tgx_member_claim"`
+	OrganizationsClaim []string             `json:"organizations_claim"`
+	IgnoreExpiration   bool                 `json:"ignore_expiration"`
+	DisableFetchNeeded bool                 `json:"disable_fetch_needed"`
+	// ServicePrincipal marks the users of every token as authorization.PrincipalService, for issuers of
+	// service tokens such as client credentials. They are authorization.PrincipalUser otherwise
+	ServicePrincipal bool `json:"service_principal"`
+	// Fetcher, when set, fetches the full token of fetch needed tokens instead of the fetcher described
+	// by ClientConfig, whose retry and circuit breaker settings still apply if it is set
+	Fetcher BearerFetcher `json:"-"`
 }
 
+// ClientConfig is the data required to fetch the full bearer of fetch needed tokens
 type ClientConfig struct {
 	FetcherURL string `json:"fetcher_url"`
+	// Query is the GraphQL query returning the full token. Defaults to { admin { jwt } }
+	Query string `json:"query"`
+	// ResponsePath is the dot separated path of the full token in the GraphQL response. Defaults to data.admin.jwt
+	ResponsePath string `json:"response_path"`
+	// Headers are added to every request to FetcherURL
+	Headers map[string]string `json:"headers"`
+	// TokenExchange, when set, makes FetcherURL an RFC 8693 token endpoint instead of a GraphQL api
+	TokenExchange *TokenExchangeConfig `json:"token_exchange"`
+	// Timeout of every request to FetcherURL. Defaults to 10s
+	Timeout time.Duration `json:"timeout"`
+	// MaxRetries is how many times a transient failure is retried. Defaults to 2, negative disables retries
+	MaxRetries int `json:"max_retries"`
+	// RetryBackoff is the base delay between retries, doubled on every retry and jittered. Defaults to 100ms
+	RetryBackoff time.Duration `json:"retry_backoff"`
+	// BreakerThreshold is the number of consecutive transient failures that opens the circuit breaker,
+	// failing fetches fast with ErrCircuitOpen. Defaults to 5, negative disables the breaker
+	BreakerThreshold int `json:"breaker_threshold"`
+	// BreakerCooldown is how long the breaker stays open before a trial request. Defaults to 30s
+	BreakerCooldown time.Duration `json:"breaker_cooldown"`
+	// CacheSize is the number of fetched bearers kept until their exp. Defaults to 1000, negative
+	// disables the cache, concurrent fetches of the same token are coalesced anyway
+	CacheSize int `json:"cache_size"`
 }
 
-func (c ClientConfig) buildClient() client {
-	return newClient(c.FetcherURL)
+// buildFetcher returns f, or the fetcher described by c when f is nil, with retries and a circuit breaker
+func (c ClientConfig) buildFetcher(f BearerFetcher) BearerFetcher {
+	if f == nil {
+		timeout := c.Timeout
+		if timeout <= 0 {
+			timeout = defaultFetcherTimeout
+		}
+		cli := &http.Client{Timeout: timeout}
+		if c.TokenExchange != nil {
+			f = &TokenExchangeFetcher{URL: c.FetcherURL, TokenExchangeConfig: *c.TokenExchange, Headers: c.Headers, Client: cli}
+		} else {
+			f = &GraphQLFetcher{URL: c.FetcherURL, Query: c.Query, ResponsePath: c.ResponsePath, Headers: c.Headers, Client: cli}
+		}
+	}
+	return newResilientFetcher(f, c)
 }
 
-// NewParser returns an instance of Parser which parses bearers from a publicKey
+// NewParser returns an instance of Parser which parses bearers from a publicKey, or from the keys
+// of a JSON Web Key Set when JWKS is configured. Invalid key material is reported on every Parse,
+// use NewParserE to get the error at construction time
 func NewParser(p ParserConfig) *Parser {
-	var client client
-	if p.ClientConfig != nil {
-		client = p.ClientConfig.buildClient()
+	parser, err := NewParserE(p)
+	if err != nil {
+		parser = newParser(p, func(token *jwt.Token) (interface{}, error) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		})
+	}
+	return parser
+}
+
+// NewParserE returns an instance of Parser, the public key is read and decoded once and must be usable
+// with at least one of the allowed algorithms. Use ParserConfig.Validate to check the rest of the config
+func NewParserE(p ParserConfig) (*Parser, error) {
+	if p.JWKS != nil {
+		return newParser(p, NewKeySet(*p.JWKS).KeyFunc), nil
 	}
 
-	jkf := func(token *jwt.Token) (interface{}, error) {
-		var result interface{}
-		result, err := jwt.ParseRSAPublicKeyFromPEM([]byte(p.PublicKey))
-		return result, err
+	key, err := p.publicKey()
+	if err != nil {
+		return nil, err
 	}
-	return &Parser{
-		KeyFunc:      jkf,
+
+	return newParser(p, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}), nil
+}
+
+func newParser(p ParserConfig, keyFunc jwt.Keyfunc) *Parser {
+	algorithms := p.allowedAlgorithms()
+	parser := &Parser{
+		KeyFunc:      algorithmKeyFunc(algorithms, keyFunc),
+		algorithms:   algorithms,
 		ParserConfig: p,
-		client:       client,
 	}
+	size := defaultFetchCacheSize
+	parser.Fetcher = p.Fetcher
+	if p.ClientConfig != nil {
+		parser.Fetcher = p.ClientConfig.buildFetcher(p.Fetcher)
+		if p.ClientConfig.CacheSize != 0 {
+			size = p.ClientConfig.CacheSize
+		}
+	}
+	parser.bearers = newBearerCache(size, parser.now)
+	return parser
 }
 
 func (p *Parser) Parse(authorizationHeader string) (*authorization.User, error) {
+	return p.ParseContext(context.Background(), authorizationHeader)
+}
+
+// ParseContext parses the header, ctx bounds the fetch of the full token of fetch needed tokens
+func (p *Parser) ParseContext(ctx context.Context, authorizationHeader string) (*authorization.User, error) {
 	// validate bearer
-	authorizationHeaderParts := strings.SplitN(authorizationHeader, " ", 2)
-	if len(authorizationHeaderParts) != 2 || authorizationHeaderParts[0] != "Bearer" {
-		return nil, fmt.Errorf("authorization header format must be Bearer {token}")
+	scheme, bearer, ok := strings.Cut(authorizationHeader, " ")
+	if !ok || scheme != "Bearer" {
+		return nil, ErrMalformedHeader
 	}
 	// dummy treatment
-	if p.DummyToken != "" && authorizationHeaderParts[1] == p.DummyToken {
-		return &authorization.User{
-			AuthorizationValue: authorizationHeader,
-			IsDummy:            true,
-			Permissions:        nil, // TODO: NoopImpl?
-		}, nil
-	}
-	// parse token
-	jwtp := &jwt.Parser{SkipClaimsValidation: p.IgnoreExpiration}
-	token, err := jwtp.Parse(authorizationHeaderParts[1], p.KeyFunc)
+	if u, ok, err := p.dummyUser(authorizationHeader, bearer); ok {
+		return u, err
+	}
+	token, claims, err := p.verify(bearer)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing bearer: %v", err)
+		return nil, err
 	}
-	if jwt.SigningMethodRS256.Alg() != token.Header["alg"] {
-		message := fmt.Sprintf("Expected %s signing method but token specified %s",
-			jwt.SigningMethodRS256.Alg(),
-			token.Header["alg"])
-		return nil, fmt.Errorf("error validating token algorithm: %s", message)
+	return p.createUser(ctx, token, claims)
+}
+
+// verify checks the signature and the claims of bearer
+func (p *Parser) verify(bearer string) (*jwt.Token, *Claims, error) {
+	// time based claims are validated by validateClaims with the parser clock and leeway
+	jwtp := &jwt.Parser{
+		ValidMethods:         p.algorithms,
+		SkipClaimsValidation: true,
+	}
+	token, err := jwtp.Parse(bearer, p.KeyFunc)
+	if err != nil {
+		return nil, nil, p.parseError(token, err)
 	}
 	// check if the parsed token is valid...
 	if !token.Valid {
-		return nil, authorization.ErrInvalidUser
+		return nil, nil, authorization.ErrInvalidUser
+	}
+	claims, err := ParseClaims(token.Claims.(jwt.MapClaims), p.ParserConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.validateClaims(claims); err != nil {
+		return nil, nil, err
+	}
+	if err := p.checkRevoked(claims); err != nil {
+		return nil, nil, err
 	}
-	return p.createUser(token)
+	return token, claims, nil
 }
 
-func (p *Parser) createUser(token *jwt.Token) (*authorization.User, error) {
-	claimsMap := token.Claims.(jwt.MapClaims)
+func (p *Parser) createUser(ctx context.Context, token *jwt.Token, claims *Claims) (*authorization.User, error) {
+	// First of all is checked if the token received in a "fullToken"
+	if !p.DisableFetchNeeded && claims.FetchNeeded && p.Fetcher != nil {
+		// the fetched token is bound to the identity of the short token, without one any token would do
+		if claims.Subject == "" && len(claims.MemberIDs) == 0 {
+			return nil, fmt.Errorf("%w: the short token has neither sub nor member id", ErrBearerUpgrade)
+		}
 
-	isTgxMember := false
-	for _, f := range p.TGXMemberClaim {
-		if c, ok := claimsMap[f]; ok {
-			if c.(bool) {
-				isTgxMember = true
-			}
+		// Get the client's "fullToken"
+		shortToken := "Bearer " + token.Raw
+		fullBearer, err := p.fetchBearer(ctx, token.Raw)
+		if err != nil {
+			return nil, &FetchError{Err: err}
+		}
+
+		// The full token is verified but never fetched again, the upgrade is one level deep
+		fullClaims, err := p.verifyUpgrade(token, claims, fullBearer)
+		if err != nil {
+			p.forgetBearer(token.Raw)
+			return nil, err
 		}
-	}
 
-	if !p.DisableFetchNeeded {
-
-		// First of all is checked if the token received in a "fullToken"
-		for _, f := range p.FetchNeededClaim {
-			if c, ok := claimsMap[f]; ok {
-				if c.(bool) {
-					if p.client != nil {
-						// Get the client's "fullToken"
-						shortToken := "Bearer " + token.Raw
-						fullBearer, err := p.client.GetBearer("", shortToken)
-						if err != nil {
-							return nil, err
-						}
-
-						// Do Parse(), recursive call with the new authorization token
-						user, err := p.Parse("Bearer " + fullBearer)
-						if err != nil {
-							return nil, err
-						}
-
-						// Set the reduced token in the response object
-						user.AuthorizationValue = shortToken
-						user.TgxMember = isTgxMember
-						return user, nil
-					}
-				}
-			}
+		// Set the reduced token in the response object
+		user := p.newUser(shortToken, fullClaims)
+		user.TgxMember = claims.TGXMember
+		// the User is cached by the short token, so revoking it must be noticed too
+		user.ShortTokenID = claims.ID
+		if !claims.IssuedAt.IsZero() {
+			user.ShortIssuedAt = float64(claims.IssuedAt.Unix())
 		}
+		return user, nil
 	}
 
 	// This way is done when the token received is a "fullToken"
 	// TODO: remove when migration finishes
-	groups := make([]interface{}, 0, len(p.GroupsClaim))
-	for _, g := range p.GroupsClaim {
-		if c, ok := claimsMap[g]; ok {
-			groups = append(groups, c)
-		}
+	return p.newUser("Bearer "+token.Raw, claims), nil
+}
+
+// verifyUpgrade checks that fullBearer is a distinct, valid full token of the subject of the short token
+func (p *Parser) verifyUpgrade(token *jwt.Token, claims *Claims, fullBearer string) (*Claims, error) {
+	switch {
+	case fullBearer == "":
+		return nil, fmt.Errorf("%w: empty bearer", ErrBearerUpgrade)
+	case fullBearer == token.Raw:
+		return nil, fmt.Errorf("%w: the fetcher returned the short token", ErrBearerUpgrade)
 	}
 
-	memberIDs := make([]string, 0, len(p.MemberIDClaim))
-	for _, m := range p.MemberIDClaim {
-		if c, ok := claimsMap[m]; ok {
-			if mID, ok := c.(string); ok {
-				memberIDs = append(memberIDs, mID)
-			}
-		}
+	_, full, err := p.verify(fullBearer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBearerUpgrade, err)
+	}
+	switch {
+	case full.FetchNeeded:
+		return nil, fmt.Errorf("%w: the fetched token needs a fetch too", ErrBearerUpgrade)
+	case claims.Subject != "" && full.Subject != claims.Subject:
+		return nil, fmt.Errorf("%w: subject %q of the fetched token doesn't match %q", ErrBearerUpgrade, full.Subject, claims.Subject)
+	case len(claims.MemberIDs) > 0 && !containsAny(full.MemberIDs, claims.MemberIDs):
+		return nil, fmt.Errorf("%w: member %v of the fetched token doesn't match %v", ErrBearerUpgrade, full.MemberIDs, claims.MemberIDs)
 	}
+	return full, nil
+}
 
-	organizations := make([]interface{}, 0, len(p.OrganizationsClaim))
-	for _, g := range p.OrganizationsClaim {
-		if c, ok := claimsMap[g]; ok {
-			organizations = append(organizations, c)
-		}
+// fetchBearer returns the full bearer of shortToken, coalescing concurrent fetches and caching the result
+func (p *Parser) fetchBearer(ctx context.Context, shortToken string) (string, error) {
+	fetch := func(ctx context.Context) (string, error) {
+		return p.Fetcher.FetchBearer(ctx, shortToken)
+	}
+	if p.bearers == nil {
+		return fetch(ctx)
 	}
+	return p.bearers.get(ctx, shortToken, fetch)
+}
 
-	exp := claimsMap["exp"]
+func (p *Parser) forgetBearer(shortToken string) {
+	if p.bearers != nil {
+		p.bearers.remove(shortToken)
+	}
+}
 
-	return &authorization.User{
-		AuthorizationValue: "Bearer " + token.Raw,
-		IsDummy:            false,
-		Permissions:        NewPermissions(groups, memberIDs, p.AdminGroup),
-		UserID:             memberIDs,
-		TgxMember:          isTgxMember,
-		IsExpired:          isExpired(exp.(float64)),
-		Expiration:         exp.(float64),
-		Orgs:               organizations,
-	}, nil
+// newUser builds the User described by claims
+func (p *Parser) newUser(authorizationValue string, claims *Claims) *authorization.User {
+	u := claims.User(authorizationValue, p.AdminGroup)
+	u.Principal = p.UserPrincipal()
+	u.IsExpired = p.isExpired(u.Expiration)
+	return u
 }
 
-func isExpired(exp float64) bool {
-	expDate := time.Unix(int64(exp), 0)
-	return expDate.Before(time.Now())
+// checkRevoked consults the Revoker with the identifiers of the token, before any full token is fetched
+func (p *Parser) checkRevoked(claims *Claims) error {
+	if p.Revoker == nil {
+		return nil
+	}
+	u := &authorization.User{UserID: claims.MemberIDs, TokenID: claims.ID}
+	if !claims.IssuedAt.IsZero() {
+		u.IssuedAt = float64(claims.IssuedAt.Unix())
+	}
+	revoked, err := p.Revoker.IsRevoked(u)
+	if err != nil {
+		return fmt.Errorf("error checking revocation: %w", err)
+	}
+	if revoked {
+		return authorization.ErrRevoked
+	}
+	return nil
+}
+
+func (p *Parser) isExpired(exp float64) bool {
+	expDate := time.Unix(int64(exp), 0).Add(p.Leeway)
+	return expDate.Before(p.now())
+}
+
+func (p *Parser) now() time.Time {
+	if p.Now == nil {
+		return time.Now()
+	}
+	return p.Now()
 }
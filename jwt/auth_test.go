@@ -0,0 +1,94 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+func TestNewParserE(t *testing.T) {
+	key := newRSAKey(t)
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	_, err = NewParserE(c)
+	assert.NoError(t, err)
+
+	c.PublicKey = "not a pem"
+	_, err = NewParserE(c)
+	assert.Error(t, err)
+
+	// an ECDSA key can't verify the default RS256
+	c.PublicKey = publicKeyPEM(t, &ec.PublicKey)
+	_, err = NewParserE(c)
+	assert.Error(t, err)
+}
+
+func TestNewParser_invalidKey(t *testing.T) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = "not a pem"
+
+	_, err := NewParser(c).Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, key, "", testClaims()))
+	assert.Error(t, err)
+}
+
+func TestParser_Revoker(t *testing.T) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	p, err := NewParserE(c)
+	require.NoError(t, err)
+
+	revoked := map[string]bool{}
+	p.Revoker = revokerFunc(func(u *authorization.User) (bool, error) {
+		return revoked[u.TokenID], nil
+	})
+
+	claims := testClaims()
+	claims["jti"] = "t1"
+	bearer := "Bearer " + signToken(t, jwt.SigningMethodRS256, key, "", claims)
+
+	u, err := p.Parse(bearer)
+	require.NoError(t, err)
+	assert.Equal(t, "t1", u.TokenID)
+
+	revoked["t1"] = true
+	_, err = p.Parse(bearer)
+	assert.True(t, errors.Is(err, authorization.ErrRevoked))
+}
+
+type revokerFunc func(u *authorization.User) (bool, error)
+
+func (f revokerFunc) IsRevoked(u *authorization.User) (bool, error) {
+	return f(u)
+}
+
+func TestParser_principal(t *testing.T) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	// a member id that isn't an email no longer makes a user a service
+	claims := testClaims()
+	claims["https://xtg.com/member_id"] = "client-1"
+	bearer := "Bearer " + signToken(t, jwt.SigningMethodRS256, key, "", claims)
+
+	u, err := NewParser(c).Parse(bearer)
+	require.NoError(t, err)
+	assert.Equal(t, authorization.PrincipalUser, u.Principal)
+	assert.False(t, u.IsServicePrincipal())
+
+	c.ServicePrincipal = true
+	u, err = NewParser(c).Parse(bearer)
+	require.NoError(t, err)
+	assert.Equal(t, authorization.PrincipalService, u.Principal)
+}
@@ -0,0 +1,89 @@
+package jwt
+
+import (
+	"testing"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func benchmarkParser(tb testing.TB) (*Parser, string) {
+	key := newRSAKey(tb)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(tb, &key.PublicKey)
+	p, err := NewParserE(c)
+	require.NoError(tb, err)
+	return p, "Bearer " + signToken(tb, jwt.SigningMethodRS256, key, "", testClaims())
+}
+
+// decodeKeyPerRequest makes p decode the PEM for every token, like the KeyFunc before the key was
+// decoded at construction time. It's an approximation of that code, the rest of Parse is today's
+func decodeKeyPerRequest(p *Parser) {
+	pem := []byte(p.PublicKey)
+	p.KeyFunc = func(token *jwt.Token) (interface{}, error) {
+		return jwt.ParseRSAPublicKeyFromPEM(pem)
+	}
+}
+
+// TestParse_keyDecodedOnce checks the saving the benchmarks measure, allocations are stable where the
+// timings of the RSA verification are too noisy to compare
+func TestParse_keyDecodedOnce(t *testing.T) {
+	p, bearer := benchmarkParser(t)
+	token, _, err := new(jwt.Parser).ParseUnverified(bearer[len("Bearer "):], jwt.MapClaims{})
+	require.NoError(t, err)
+	keyAllocs := testing.AllocsPerRun(100, func() { p.KeyFunc(token) })
+	assert.Zero(t, keyAllocs)
+
+	parse := func(p *Parser) float64 {
+		return testing.AllocsPerRun(100, func() {
+			if _, err := p.Parse(bearer); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	decodedOnce := parse(p)
+	perRequest, err := NewParserE(p.ParserConfig)
+	require.NoError(t, err)
+	decodeKeyPerRequest(perRequest)
+	assert.Less(t, decodedOnce, parse(perRequest))
+}
+
+// BenchmarkParse measures the hot path with the key decoded at construction time
+func BenchmarkParse(b *testing.B) {
+	p, bearer := benchmarkParser(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Parse(bearer); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParse_keyPerRequest is BenchmarkParse decoding the PEM for every token, see
+// decodeKeyPerRequest
+func BenchmarkParse_keyPerRequest(b *testing.B) {
+	p, bearer := benchmarkParser(b)
+	decodeKeyPerRequest(p)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Parse(bearer); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkKeyFunc(b *testing.B) {
+	p, bearer := benchmarkParser(b)
+	token, _, err := new(jwt.Parser).ParseUnverified(bearer[len("Bearer "):], jwt.MapClaims{})
+	require.NoError(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.KeyFunc(token); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a consecutive failures circuit breaker. Once threshold failures are recorded in a row
+// it rejects calls until cooldown elapses, then lets a single trial call through per cooldown until
+// one succeeds. A non positive threshold disables it
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	// half open, the timer is restarted so concurrent calls keep failing fast until the trial completes
+	if now := b.now(); now.Sub(b.openedAt) >= b.cooldown {
+		b.openedAt = now
+		return true
+	}
+	return false
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	b.failures = 0
+	b.mu.Unlock()
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+	b.mu.Unlock()
+}
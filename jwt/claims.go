@@ -0,0 +1,262 @@
+package jwt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+// Claims is the typed representation of the token claims a Parser reads. Every claim is type checked
+// when decoded, so building a User from Claims can't fail
+type Claims struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	ID       string
+	// ExpiresAt, NotBefore and IssuedAt are zero when the claim is absent
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+
+	MemberIDs []string
+	// Scopes are the values of the scope claim, a space separated string or an array of strings
+	Scopes []string
+	// Groups holds the raw values of GroupsClaim
+	Groups []interface{}
+	// Organizations are decoded from the first OrganizationsClaim present, the others are aliases
+	Organizations []authorization.Organization
+	TGXMember     bool
+	FetchNeeded   bool
+
+	Raw map[string]interface{}
+}
+
+// ParseClaims decodes the registered claims and the claims named in c. A *ClaimError is returned for
+// every mistyped claim, for a missing exp unless c.IgnoreExpiration and for a missing member id unless
+// the token needs to be fetched
+func ParseClaims(m map[string]interface{}, c ParserConfig) (*Claims, error) {
+	claims := &Claims{Raw: m}
+	var err error
+
+	if claims.Issuer, err = stringClaim(m, "iss"); err != nil {
+		return nil, err
+	}
+	if claims.Subject, err = stringClaim(m, "sub"); err != nil {
+		return nil, err
+	}
+	if claims.ID, err = stringClaim(m, "jti"); err != nil {
+		return nil, err
+	}
+	if claims.Audience, err = audienceClaim(m); err != nil {
+		return nil, err
+	}
+	if claims.Scopes, err = scopeClaim(m); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt, err = numericDateClaim(m, "exp"); err != nil {
+		return nil, err
+	}
+	if claims.NotBefore, err = numericDateClaim(m, "nbf"); err != nil {
+		return nil, err
+	}
+	if claims.IssuedAt, err = numericDateClaim(m, "iat"); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt.IsZero() && !c.IgnoreExpiration {
+		return nil, &ClaimError{Claim: "exp", Err: ErrMissingClaim}
+	}
+
+	if claims.TGXMember, err = boolClaims(m, c.TGXMemberClaim); err != nil {
+		return nil, err
+	}
+	if claims.FetchNeeded, err = boolClaims(m, c.FetchNeededClaim); err != nil {
+		return nil, err
+	}
+	if claims.Groups, err = arrayClaims(m, c.GroupsClaim); err != nil {
+		return nil, err
+	}
+	if claims.Organizations, err = organizationsClaims(m, c.OrganizationsClaim); err != nil {
+		return nil, err
+	}
+
+	for _, name := range c.MemberIDClaim {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		id, ok := v.(string)
+		if !ok || id == "" {
+			return nil, &ClaimError{Claim: name, Value: v, Err: ErrInvalidClaim}
+		}
+		claims.MemberIDs = append(claims.MemberIDs, id)
+	}
+	if len(c.MemberIDClaim) > 0 && len(claims.MemberIDs) == 0 && !claims.FetchNeeded {
+		return nil, &ClaimError{Claim: strings.Join(c.MemberIDClaim, "|"), Err: ErrMissingClaim}
+	}
+
+	return claims, nil
+}
+
+// User builds the User described by the claims, adminGroup is handed to NewPermissions. The User is a
+// authorization.PrincipalUser, see ParserConfig.UserPrincipal. IsExpired is left for the caller, which
+// owns the clock
+func (c *Claims) User(authorizationValue string, adminGroup string) *authorization.User {
+	memberIDs := c.MemberIDs
+	if memberIDs == nil {
+		memberIDs = []string{}
+	}
+
+	var exp, iat float64
+	if !c.ExpiresAt.IsZero() {
+		exp = float64(c.ExpiresAt.Unix())
+	}
+	if !c.IssuedAt.IsZero() {
+		iat = float64(c.IssuedAt.Unix())
+	}
+
+	return &authorization.User{
+		AuthorizationValue: authorizationValue,
+		IsDummy:            false,
+		Permissions:        NewPermissions(c.Groups, memberIDs, adminGroup),
+		UserID:             memberIDs,
+		Issuer:             c.Issuer,
+		Scopes:             c.Scopes,
+		TgxMember:          c.TGXMember,
+		Expiration:         exp,
+		IssuedAt:           iat,
+		TokenID:            c.ID,
+		Orgs:               c.Organizations,
+		Principal:          authorization.PrincipalUser,
+	}
+}
+
+func stringClaim(m map[string]interface{}, name string) (string, error) {
+	v, ok := m[name]
+	if !ok {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", &ClaimError{Claim: name, Value: v, Err: ErrInvalidClaim}
+	}
+	return s, nil
+}
+
+// numericDateClaim returns the time of a NumericDate claim, or the zero time when absent
+func numericDateClaim(m map[string]interface{}, name string) (time.Time, error) {
+	v, ok := m[name]
+	if !ok {
+		return time.Time{}, nil
+	}
+	f, ok := v.(float64)
+	if !ok || f != f || f < 0 || f > maxNumericDate {
+		return time.Time{}, &ClaimError{Claim: name, Value: v, Err: ErrInvalidClaim}
+	}
+	return time.Unix(int64(f), 0), nil
+}
+
+// maxNumericDate keeps the unix seconds of a NumericDate representable as a time.Time
+const maxNumericDate = 1 << 40
+
+// audienceClaim returns the aud claim values, which may be a single string or an array of strings
+func audienceClaim(m map[string]interface{}) ([]string, error) {
+	switch v := m["aud"].(type) {
+	case nil:
+		if _, ok := m["aud"]; ok {
+			return nil, &ClaimError{Claim: "aud", Err: ErrInvalidClaim}
+		}
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, a := range v {
+			s, ok := a.(string)
+			if !ok {
+				return nil, &ClaimError{Claim: "aud", Value: v, Err: ErrInvalidClaim}
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	default:
+		return nil, &ClaimError{Claim: "aud", Value: v, Err: ErrInvalidClaim}
+	}
+}
+
+// scopeClaim returns the scope claim values, which may be a space separated string or an array of
+// strings
+func scopeClaim(m map[string]interface{}) ([]string, error) {
+	switch v := m["scope"].(type) {
+	case nil:
+		if _, ok := m["scope"]; ok {
+			return nil, &ClaimError{Claim: "scope", Err: ErrInvalidClaim}
+		}
+		return nil, nil
+	case string:
+		return strings.Fields(v), nil
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			s, ok := s.(string)
+			if !ok {
+				return nil, &ClaimError{Claim: "scope", Value: v, Err: ErrInvalidClaim}
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	default:
+		return nil, &ClaimError{Claim: "scope", Value: v, Err: ErrInvalidClaim}
+	}
+}
+
+// boolClaims reports whether any of the present claims is true
+func boolClaims(m map[string]interface{}, names []string) (bool, error) {
+	result := false
+	for _, name := range names {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return false, &ClaimError{Claim: name, Value: v, Err: ErrInvalidClaim}
+		}
+		result = result || b
+	}
+	return result, nil
+}
+
+// organizationsClaims decodes the organizations of the first present claim. The names are aliases of
+// the same claim, so a token carrying several would otherwise list every organization more than once
+func organizationsClaims(m map[string]interface{}, names []string) ([]authorization.Organization, error) {
+	for _, name := range names {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		orgs, err := authorization.ParseOrganizations(v)
+		if err != nil {
+			return nil, &ClaimError{Claim: name, Value: v, Err: fmt.Errorf("%w: %w", ErrInvalidClaim, err)}
+		}
+		return orgs, nil
+	}
+	return nil, nil
+}
+
+// arrayClaims returns the values of the present claims, each of them must be an array
+func arrayClaims(m map[string]interface{}, names []string) ([]interface{}, error) {
+	out := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		if _, ok := v.([]interface{}); !ok {
+			return nil, &ClaimError{Claim: name, Value: v, Err: ErrInvalidClaim}
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
@@ -0,0 +1,142 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+func claimsTestConfig() ParserConfig {
+	return ParserConfig{
+		MemberIDClaim:      []string{"https://xtg.com/member_id"},
+		GroupsClaim:        []string{"https://xtg.com/iam"},
+		FetchNeededClaim:   []string{"https://xtg.com/fetch_needed"},
+		TGXMemberClaim:     []string{"https://xtg.com/is_tgx"},
+		OrganizationsClaim: []string{"https://xtg.com/org"},
+	}
+}
+
+func TestParseClaims_malformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims string
+		claim  string
+		err    error
+	}{
+		{"missing exp", `{"https://xtg.com/member_id": "a@b.com"}`, "exp", ErrMissingClaim},
+		{"string exp", `{"exp": "1700000000", "https://xtg.com/member_id": "a@b.com"}`, "exp", ErrInvalidClaim},
+		{"missing member id", `{"exp": 1700000000}`, "https://xtg.com/member_id", ErrMissingClaim},
+		{"numeric member id", `{"exp": 1700000000, "https://xtg.com/member_id": 3}`, "https://xtg.com/member_id", ErrInvalidClaim},
+		{"string tgx member", `{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com", "https://xtg.com/is_tgx": "true"}`, "https://xtg.com/is_tgx", ErrInvalidClaim},
+		{"string fetch needed", `{"exp": 1700000000, "https://xtg.com/fetch_needed": "true"}`, "https://xtg.com/fetch_needed", ErrInvalidClaim},
+		{"object groups", `{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com", "https://xtg.com/iam": {}}`, "https://xtg.com/iam", ErrInvalidClaim},
+		{"string orgs", `{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com", "https://xtg.com/org": "tgx"}`, "https://xtg.com/org", ErrInvalidClaim},
+		{"unknown org role", `{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com", "https://xtg.com/org": [{"o": "tgx", "r": "ROOT"}]}`, "https://xtg.com/org", authorization.ErrUnknownRole},
+		{"org without code", `{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com", "https://xtg.com/org": [{"r": "ADMIN"}]}`, "https://xtg.com/org", ErrInvalidClaim},
+		{"numeric audience", `{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com", "aud": 1}`, "aud", ErrInvalidClaim},
+		{"numeric issuer", `{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com", "iss": 1}`, "iss", ErrInvalidClaim},
+		{"numeric scope", `{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com", "scope": ["read", 1]}`, "scope", ErrInvalidClaim},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.claims), &m))
+
+			_, err := ParseClaims(m, claimsTestConfig())
+			var claimErr *ClaimError
+			require.True(t, errors.As(err, &claimErr), "unexpected error %v", err)
+			assert.Equal(t, tt.claim, claimErr.Claim)
+			assert.True(t, errors.Is(err, tt.err))
+			assert.True(t, errors.Is(err, authorization.ErrUnauthenticated))
+		})
+	}
+}
+
+func TestParseClaims(t *testing.T) {
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"iss": "https://iam.xtg.com/", "sub": "auth0|1", "jti": "id1", "aud": ["hotelx"],
+		"exp": 1700000000, "iat": 1600000000,
+		"https://xtg.com/member_id": "a@b.com", "https://xtg.com/is_tgx": true,
+		"https://xtg.com/org": [{"o": "tgx", "r": "ADMIN"}]
+	}`), &m))
+
+	c, err := ParseClaims(m, claimsTestConfig())
+	require.NoError(t, err)
+	assert.Equal(t, "https://iam.xtg.com/", c.Issuer)
+	assert.Equal(t, "auth0|1", c.Subject)
+	assert.Equal(t, "id1", c.ID)
+	assert.Equal(t, []string{"hotelx"}, c.Audience)
+	assert.EqualValues(t, 1700000000, c.ExpiresAt.Unix())
+	assert.EqualValues(t, 1600000000, c.IssuedAt.Unix())
+	assert.True(t, c.NotBefore.IsZero())
+	assert.Equal(t, []string{"a@b.com"}, c.MemberIDs)
+	assert.True(t, c.TGXMember)
+	assert.False(t, c.FetchNeeded)
+	assert.Equal(t, []authorization.Organization{{Code: "tgx", Role: authorization.ADMIN}}, c.Organizations)
+}
+
+func TestParseClaims_scope(t *testing.T) {
+	for _, scope := range []string{`"read  write"`, `["read", "write"]`} {
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(`{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com", "scope": `+scope+`}`), &m))
+
+		c, err := ParseClaims(m, claimsTestConfig())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"read", "write"}, c.Scopes, scope)
+	}
+}
+
+// FuzzParseClaims checks that no claim map, however malformed, panics while building a User
+func FuzzParseClaims(f *testing.F) {
+	f.Add(`{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com"}`)
+	f.Add(`{"exp": "x", "https://xtg.com/is_tgx": "true", "https://xtg.com/fetch_needed": 1}`)
+	f.Add(`{"exp": 1e300, "https://xtg.com/member_id": "a@b.com", "https://xtg.com/is_tgx": true, "https://xtg.com/org": [1, [2], {"o": 3}]}`)
+	f.Add(`{"exp": 1700000000, "https://xtg.com/member_id": "a@b.com", "https://xtg.com/iam": [[{"c": "g", "t": "gr", "p": {"p1": {"o1": [1, "crud1"]}}, "g": {"x": 1}}]]}`)
+	f.Add(`{"aud": [null], "nbf": -1, "iat": null}`)
+
+	p := &Parser{ParserConfig: claimsTestConfig()}
+	f.Fuzz(func(t *testing.T, data string) {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &m); err != nil {
+			return
+		}
+		claims, err := ParseClaims(jwt.MapClaims(m), p.ParserConfig)
+		if err != nil {
+			var claimErr *ClaimError
+			if !errors.As(err, &claimErr) {
+				t.Fatalf("unexpected error type %T", err)
+			}
+			return
+		}
+		_ = p.validateClaims(claims)
+
+		u := p.newUser("Bearer x", claims)
+		u.GetOrgs(authorization.VIEWER)
+		u.IsTGXMemberRole(authorization.VIEWER, nil)
+		u.Permissions.CheckPermission("p1", "o1", authorization.Read)
+		u.Permissions.GetGroupsByTypes()
+		u.Permissions.GetParents("g")
+	})
+}
+
+func TestParseClaims_organizationAliases(t *testing.T) {
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"exp": 1700000000, "https://xtg.com/member_id": "a@b.com",
+		"https://xtg.com/org": [{"o": "org1", "r": "ADMIN"}],
+		"https://travelgatex.com/org": [{"o": "org1", "r": "ADMIN"}]
+	}`), &m))
+
+	config := claimsTestConfig()
+	config.OrganizationsClaim = []string{"https://xtg.com/org", "https://travelgatex.com/org"}
+	c, err := ParseClaims(m, config)
+	require.NoError(t, err)
+	assert.Equal(t, []authorization.Organization{{Code: "org1", Role: authorization.ADMIN}}, c.Organizations)
+	assert.Equal(t, []string{"org1"}, c.User("", "").GetOrgs(authorization.VIEWER))
+}
@@ -1,15 +1,31 @@
 package jwt
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"math/rand"
+	"net"
 	"net/http"
+	"time"
 )
 
-type client interface {
-	GetBearer(userID, authHeader string) (string, error)
+const (
+	defaultFetcherTimeout          = 10 * time.Second
+	defaultFetcherRetries          = 2
+	defaultFetcherRetryBackoff     = 100 * time.Millisecond
+	maxFetcherRetryBackoff         = 2 * time.Second
+	defaultFetcherBreakerThreshold = 5
+	defaultFetcherBreakerCooldown  = 30 * time.Second
+	maxFetcherResponseSize         = 1 << 20
+)
+
+// BearerFetcher exchanges the short token of a fetch needed bearer, without the Bearer scheme,
+// for the full token. Transient failures should be reported as a *StatusError or a net.Error so
+// they are retried when the fetcher is configured through ClientConfig
+type BearerFetcher interface {
+	FetchBearer(ctx context.Context, shortToken string) (string, error)
 }
 
 type GetBearerResponseStruct struct {
@@ -19,53 +35,119 @@ type GetBearerResponseStruct struct {
 	Status           int    `json:"status,omitempty"`
 }
 
-type fetcherClient struct {
-	cli http.Client
-	url string
+// resilientFetcher retries the transient failures of a BearerFetcher and guards it with a circuit breaker
+type resilientFetcher struct {
+	fetcher BearerFetcher
+	config  ClientConfig
+	breaker *breaker
+	// sleep waits between retries, it returns early with the context error
+	sleep func(ctx context.Context, d time.Duration) error
 }
 
-func newClient(url string) client {
-	cli := http.Client{}
-	return &fetcherClient{cli, url}
+func newResilientFetcher(f BearerFetcher, c ClientConfig) *resilientFetcher {
+	if c.MaxRetries == 0 {
+		c.MaxRetries = defaultFetcherRetries
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = defaultFetcherRetryBackoff
+	}
+	if c.BreakerThreshold == 0 {
+		c.BreakerThreshold = defaultFetcherBreakerThreshold
+	}
+	if c.BreakerCooldown <= 0 {
+		c.BreakerCooldown = defaultFetcherBreakerCooldown
+	}
+	return &resilientFetcher{
+		fetcher: f,
+		config:  c,
+		breaker: newBreaker(c.BreakerThreshold, c.BreakerCooldown),
+		sleep:   sleepContext,
+	}
 }
 
-// GetBearer returns user bearer
-func (a *fetcherClient) GetBearer(userID, authHeader string) (string, error) {
+// FetchBearer returns the full token. Transport errors, timeouts and 408, 429 and 5xx responses are
+// retried up to MaxRetries times, and count as failures for the circuit breaker
+func (a *resilientFetcher) FetchBearer(ctx context.Context, shortToken string) (string, error) {
+	for attempt := 0; ; attempt++ {
+		if !a.breaker.allow() {
+			return "", ErrCircuitOpen
+		}
 
-	query := `{"query": "{ admin { jwt } }"}`
+		token, err := a.fetcher.FetchBearer(ctx, shortToken)
+		switch {
+		case err == nil:
+			a.breaker.success()
+			return token, nil
+		case ctx.Err() != nil:
+			// the caller gave up, that says nothing about the fetcher
+			return "", err
+		case !retryable(err):
+			// the fetcher answered, it is up
+			a.breaker.success()
+			return "", err
+		}
 
-	req, err := http.NewRequest("POST", a.url, bytes.NewBuffer([]byte(query)))
-	if err != nil {
-		return "", err
+		a.breaker.failure()
+		if attempt >= a.config.MaxRetries {
+			return "", err
+		}
+		if err := a.sleep(ctx, a.backoff(attempt)); err != nil {
+			return "", err
+		}
 	}
+}
 
-	req.Header.Add("Authorization", authHeader)
-	req.Header.Add("Content-Type", "application/json")
+// backoff doubles RetryBackoff on every attempt, capped, and picks a random duration in its upper half
+func (a *resilientFetcher) backoff(attempt int) time.Duration {
+	d := a.config.RetryBackoff << uint(attempt)
+	if d <= 0 || d > maxFetcherRetryBackoff {
+		d = maxFetcherRetryBackoff
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
 
-	res, err := a.cli.Do(req)
-	if err != nil {
-		return "", err
+// retryable reports whether err is a transient failure of the fetcher
+func retryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Temporary()
 	}
-	defer res.Body.Close()
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF)
+}
 
-	body, err := ioutil.ReadAll(res.Body)
+// doFetch sends req and returns the body of a 2xx response, other statuses fail with a *StatusError
+func doFetch(cli *http.Client, req *http.Request) ([]byte, error) {
+	if cli == nil {
+		cli = &http.Client{Timeout: defaultFetcherTimeout}
+	}
+	res, err := cli.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer res.Body.Close()
 
-	var response struct {
-		Data   map[string]map[string]string `json:"data"`
-		Errors []map[string]interface{}     `json:"errors,omitempty"`
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		// drain the body so the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(res.Body, maxFetcherResponseSize))
+		return nil, &StatusError{StatusCode: res.StatusCode, Status: res.Status}
 	}
 
-	err = json.Unmarshal(body, &response)
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxFetcherResponseSize))
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("reading fetcher response: %w", err)
 	}
+	return body, nil
+}
 
-	if len(response.Errors) > 0 {
-		return "", fmt.Errorf("error fetching permissions data: %v", response.Errors)
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
 	}
-
-	return response.Data["admin"]["jwt"], nil
 }
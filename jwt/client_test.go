@@ -0,0 +1,255 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+const fetchNeededClaim = "https://xtg.com/fetch_needed"
+
+// newFetcherServer stands in for the admin api, answering the GraphQL jwt query with handler's result
+func newFetcherServer(handler func(w http.ResponseWriter, r *http.Request) (string, bool)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := handler(w, r)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"admin": map[string]interface{}{"jwt": token}},
+		})
+	}))
+}
+
+func fetchNeededParser(t *testing.T, url string) (*Parser, func(jwt.MapClaims) string) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	c.FetchNeededClaim = []string{fetchNeededClaim}
+	c.ClientConfig = &ClientConfig{FetcherURL: url}
+	p, err := NewParserE(c)
+	require.NoError(t, err)
+	return p, func(claims jwt.MapClaims) string {
+		return signToken(t, jwt.SigningMethodRS256, key, "", claims)
+	}
+}
+
+func shortClaims() jwt.MapClaims {
+	claims := testClaims()
+	claims[fetchNeededClaim] = true
+	return claims
+}
+
+func TestParser_fetchNeeded(t *testing.T) {
+	var sign func(jwt.MapClaims) string
+	srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		return sign(testClaims()), true
+	})
+	defer srv.Close()
+	p, signer := fetchNeededParser(t, srv.URL)
+	sign = signer
+
+	short := "Bearer " + sign(shortClaims())
+	u, err := p.Parse(short)
+	require.NoError(t, err)
+	assert.Equal(t, short, u.AuthorizationValue)
+	assert.Equal(t, []string{"user@xtg.com"}, u.UserID)
+}
+
+func TestParser_fetchNeededContextCancelled(t *testing.T) {
+	release := make(chan struct{})
+	srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		<-release
+		return "", false
+	})
+	defer srv.Close()
+	defer close(release)
+	p, sign := fetchNeededParser(t, srv.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := p.ParseContext(ctx, "Bearer "+sign(shortClaims()))
+
+	var fetchErr *FetchError
+	assert.True(t, errors.As(err, &fetchErr))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+	assert.True(t, errors.Is(err, authorization.ErrUnavailable))
+}
+
+func TestParser_fetchNeededRejected(t *testing.T) {
+	srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return "", false
+	})
+	defer srv.Close()
+	p, sign := fetchNeededParser(t, srv.URL)
+
+	_, err := p.Parse("Bearer " + sign(shortClaims()))
+	var se *StatusError
+	require.True(t, errors.As(err, &se), "unexpected error %v", err)
+	assert.Equal(t, http.StatusUnauthorized, se.StatusCode)
+	assert.True(t, errors.Is(err, authorization.ErrUnauthenticated))
+	assert.False(t, errors.Is(err, authorization.ErrUnavailable))
+}
+
+// newTestClient returns the fetcher described by c, it doesn't sleep between retries
+func newTestClient(c ClientConfig) *resilientFetcher {
+	cli := c.buildFetcher(nil).(*resilientFetcher)
+	cli.sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+	return cli
+}
+
+// faultServer answers the statuses in order, then succeeds
+func faultServer(statuses ...int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		n := atomic.AddInt32(&calls, 1)
+		if int(n) <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return "", false
+		}
+		return "full", true
+	})
+	return srv, &calls
+}
+
+func TestFetcherClient_retries(t *testing.T) {
+	srv, calls := faultServer(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer srv.Close()
+
+	bearer, err := newTestClient(ClientConfig{FetcherURL: srv.URL}).FetchBearer(context.Background(), "short")
+	require.NoError(t, err)
+	assert.Equal(t, "full", bearer)
+	assert.EqualValues(t, 3, atomic.LoadInt32(calls))
+}
+
+func TestFetcherClient_retriesExhausted(t *testing.T) {
+	srv, calls := faultServer(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	defer srv.Close()
+
+	_, err := newTestClient(ClientConfig{FetcherURL: srv.URL, MaxRetries: 1}).FetchBearer(context.Background(), "short")
+	var se *StatusError
+	require.True(t, errors.As(err, &se))
+	assert.Equal(t, http.StatusBadGateway, se.StatusCode)
+	assert.EqualValues(t, 2, atomic.LoadInt32(calls))
+}
+
+func TestFetcherClient_noRetry(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotImplemented} {
+		srv, calls := faultServer(status)
+		_, err := newTestClient(ClientConfig{FetcherURL: srv.URL}).FetchBearer(context.Background(), "short")
+		srv.Close()
+
+		var se *StatusError
+		require.True(t, errors.As(err, &se), "status %d", status)
+		assert.EqualValues(t, 1, atomic.LoadInt32(calls), "status %d", status)
+	}
+
+	srv, calls := faultServer(http.StatusServiceUnavailable)
+	defer srv.Close()
+	_, err := newTestClient(ClientConfig{FetcherURL: srv.URL, MaxRetries: -1}).FetchBearer(context.Background(), "short")
+	assert.Error(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(calls))
+}
+
+func TestFetcherClient_timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		return "", false
+	})
+	defer srv.Close()
+	defer close(release)
+
+	start := time.Now()
+	c := newTestClient(ClientConfig{FetcherURL: srv.URL, Timeout: 20 * time.Millisecond, MaxRetries: 1})
+	_, err := c.FetchBearer(context.Background(), "short")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestFetcherClient_circuitBreaker(t *testing.T) {
+	var failing int32 = 1
+	var calls int32
+	srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return "", false
+		}
+		return "full", true
+	})
+	defer srv.Close()
+
+	now := time.Now()
+	c := newTestClient(ClientConfig{FetcherURL: srv.URL, MaxRetries: -1, BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	c.breaker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := c.FetchBearer(ctx, "short")
+		var se *StatusError
+		assert.True(t, errors.As(err, &se))
+	}
+
+	// open, fails fast without calling the fetcher
+	_, err := c.FetchBearer(ctx, "short")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.True(t, errors.Is(&FetchError{Err: err}, authorization.ErrUnavailable))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	// half open, the trial fails and the breaker opens again
+	now = now.Add(time.Minute)
+	_, err = c.FetchBearer(ctx, "short")
+	assert.NotEqual(t, ErrCircuitOpen, err)
+	_, err = c.FetchBearer(ctx, "short")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	// the fetcher recovers, the trial closes the breaker
+	atomic.StoreInt32(&failing, 0)
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		bearer, err := c.FetchBearer(ctx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, "full", bearer)
+	}
+}
+
+func TestFetcherClient_cancelledDoesNotOpenBreaker(t *testing.T) {
+	srv, calls := faultServer()
+	defer srv.Close()
+
+	c := newTestClient(ClientConfig{FetcherURL: srv.URL, BreakerThreshold: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.FetchBearer(ctx, "short")
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	bearer, err := c.FetchBearer(context.Background(), "short")
+	require.NoError(t, err)
+	assert.Equal(t, "full", bearer)
+	assert.EqualValues(t, 1, atomic.LoadInt32(calls))
+}
+
+func TestFetcherClient_backoff(t *testing.T) {
+	c := newTestClient(ClientConfig{RetryBackoff: 100 * time.Millisecond})
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		d := c.backoff(attempt)
+		assert.True(t, d >= max/2 && d <= max, "attempt %d: %v", attempt, d)
+	}
+	assert.True(t, c.backoff(40) <= maxFetcherRetryBackoff)
+}
@@ -0,0 +1,284 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+// LoadConfig reads a ParserConfig from a JSON file, see ParseConfig
+func LoadConfig(path string) (ParserConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ParserConfig{}, err
+	}
+	defer f.Close()
+	return ParseConfig(f)
+}
+
+// ParseConfig decodes a JSON ParserConfig. Durations are either a time.ParseDuration string ("30s")
+// or integer nanoseconds. Unknown fields are rejected so misspelled options don't go unnoticed, the
+// result still has to be checked with Validate
+func ParseConfig(r io.Reader) (ParserConfig, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return ParserConfig{}, fmt.Errorf("decoding parser config: %v", err)
+	}
+	if err := parseDurations(raw, configDurations[""]); err != nil {
+		return ParserConfig{}, fmt.Errorf("decoding parser config: %v", err)
+	}
+	for object, names := range configDurations {
+		if object == "" || raw[object] == nil {
+			continue
+		}
+		var nested map[string]json.RawMessage
+		if json.Unmarshal(raw[object], &nested) != nil || nested == nil {
+			// left for the decoder to report
+			continue
+		}
+		if err := parseDurations(nested, names); err != nil {
+			return ParserConfig{}, fmt.Errorf("decoding parser config: %s.%v", object, err)
+		}
+		raw[object], _ = json.Marshal(nested)
+	}
+	data, _ := json.Marshal(raw)
+
+	var c ParserConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return ParserConfig{}, fmt.Errorf("decoding parser config: %v", err)
+	}
+	return c, nil
+}
+
+// configDurations names the duration fields of the config objects, by the field holding the object
+var configDurations = map[string][]string{
+	"":              {"leeway"},
+	"jwks":          {"refresh_interval", "min_refresh_interval", "timeout"},
+	"client_config": {"timeout", "retry_backoff", "breaker_cooldown"},
+}
+
+// parseDurations replaces the duration strings of the named fields of object with their nanoseconds
+func parseDurations(object map[string]json.RawMessage, names []string) error {
+	for _, name := range names {
+		var s string
+		if v, ok := object[name]; !ok || json.Unmarshal(v, &s) != nil {
+			continue
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		object[name] = json.RawMessage(strconv.FormatInt(int64(d), 10))
+	}
+	return nil
+}
+
+// LoadConfigEnv reads a ParserConfig from the environment variables starting with prefix, e.g. with
+// prefix JWT_: JWT_PUBLIC_KEY, JWT_PUBLIC_KEY_FILE, JWT_JWKS_URL, JWT_JWKS_FILE, JWT_ALLOWED_ALGORITHMS,
+// JWT_ISSUERS, JWT_AUDIENCES, JWT_LEEWAY, JWT_ADMIN_GROUP, JWT_MEMBER_ID_CLAIM, JWT_GROUPS_CLAIM,
+// JWT_FETCH_NEEDED_CLAIM, JWT_TGX_MEMBER_CLAIM, JWT_ORGANIZATIONS_CLAIM, JWT_IGNORE_EXPIRATION,
+// JWT_DISABLE_FETCH_NEEDED, JWT_SERVICE_PRINCIPAL, JWT_FETCHER_URL and JWT_FETCHER_TIMEOUT. Lists are comma separated and
+// durations use the time.ParseDuration format. Escaped newlines (\n) in JWT_PUBLIC_KEY are unescaped
+func LoadConfigEnv(prefix string) (ParserConfig, error) {
+	e := envReader{prefix: prefix}
+	c := ParserConfig{
+		PublicKey:          strings.ReplaceAll(e.string("PUBLIC_KEY"), `\n`, "\n"),
+		PublicKeyFile:      e.string("PUBLIC_KEY_FILE"),
+		AllowedAlgorithms:  e.list("ALLOWED_ALGORITHMS"),
+		Issuers:            e.list("ISSUERS"),
+		Audiences:          e.list("AUDIENCES"),
+		Leeway:             e.duration("LEEWAY"),
+		AdminGroup:         e.string("ADMIN_GROUP"),
+		MemberIDClaim:      e.list("MEMBER_ID_CLAIM"),
+		GroupsClaim:        e.list("GROUPS_CLAIM"),
+		FetchNeededClaim:   e.list("FETCH_NEEDED_CLAIM"),
+		TGXMemberClaim:     e.list("TGX_MEMBER_CLAIM"),
+		OrganizationsClaim: e.list("ORGANIZATIONS_CLAIM"),
+		IgnoreExpiration:   e.bool("IGNORE_EXPIRATION"),
+		DisableFetchNeeded: e.bool("DISABLE_FETCH_NEEDED"),
+		ServicePrincipal:   e.bool("SERVICE_PRINCIPAL"),
+	}
+	if url, file := e.string("JWKS_URL"), e.string("JWKS_FILE"); url != "" || file != "" {
+		c.JWKS = &JWKSConfig{URL: url, File: file}
+	}
+	if url := e.string("FETCHER_URL"); url != "" {
+		c.ClientConfig = &ClientConfig{FetcherURL: url, Timeout: e.duration("FETCHER_TIMEOUT")}
+	}
+	if len(e.errs) > 0 {
+		return ParserConfig{}, errors.Join(e.errs...)
+	}
+	return c, nil
+}
+
+type envReader struct {
+	prefix string
+	errs   []error
+}
+
+func (e *envReader) string(name string) string {
+	return strings.TrimSpace(os.Getenv(e.prefix + name))
+}
+
+func (e *envReader) list(name string) []string {
+	v := e.string(name)
+	if v == "" {
+		return nil
+	}
+	var l []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			l = append(l, s)
+		}
+	}
+	return l
+}
+
+func (e *envReader) bool(name string) bool {
+	v := e.string(name)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s%s: %v", e.prefix, name, err))
+	}
+	return b
+}
+
+func (e *envReader) duration(name string) time.Duration {
+	v := e.string(name)
+	if v == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s%s: %v", e.prefix, name, err))
+	}
+	return d
+}
+
+// Validate reports every option, or combination of options, the Parser can't work with. The
+// returned error joins one error per problem
+func (p ParserConfig) Validate() error {
+	var errs []error
+	report := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	sources := 0
+	for _, set := range []bool{p.PublicKey != "", p.PublicKeyFile != "", p.JWKS != nil} {
+		if set {
+			sources++
+		}
+	}
+	switch {
+	case sources == 0:
+		report("one of public_key_str, public_key_file or jwks is required")
+	case sources > 1:
+		report("public_key_str, public_key_file and jwks are mutually exclusive")
+	case p.JWKS != nil:
+		if (p.JWKS.URL == "") == (p.JWKS.File == "") {
+			report("jwks: exactly one of url or file is required")
+		}
+	default:
+		if _, err := p.publicKey(); err != nil {
+			report("%v", err)
+		}
+	}
+
+	for _, alg := range p.AllowedAlgorithms {
+		if _, ok := supportedAlgorithms[alg]; !ok {
+			report("allowed_algorithms: unsupported algorithm %q", alg)
+		}
+	}
+	if p.Leeway < 0 {
+		report("leeway must not be negative")
+	}
+
+	if len(p.MemberIDClaim) == 0 {
+		report("member_id_claim is required")
+	}
+	for name, claims := range map[string][]string{
+		"member_id_claim":     p.MemberIDClaim,
+		"groups_claim":        p.GroupsClaim,
+		"fetch_needed_claim":  p.FetchNeededClaim,
+		"tgx_member_claim":    p.TGXMemberClaim,
+		"organizations_claim": p.OrganizationsClaim,
+	} {
+		for _, c := range claims {
+			if strings.TrimSpace(c) == "" {
+				report("%s: empty claim name", name)
+			}
+		}
+	}
+
+	// disable_fetch_needed switches fetching off at runtime, the client is kept configured for when it's
+	// switched back on
+	switch {
+	case len(p.FetchNeededClaim) > 0 && !p.DisableFetchNeeded && p.ClientConfig == nil && p.Fetcher == nil:
+		report("fetch_needed_claim requires client_config, fetch needed tokens would be parsed as full tokens")
+	}
+	if c := p.ClientConfig; c != nil && p.Fetcher == nil {
+		if u, err := url.Parse(c.FetcherURL); err != nil || u.Scheme == "" || u.Host == "" {
+			report("client_config: invalid fetcher_url %q", c.FetcherURL)
+		}
+	}
+
+	if p.EnableDummyTokens && p.DummyToken == "" && len(p.DummyUsers) == 0 {
+		report("enable_dummy_tokens is set but neither dummy_token nor dummy_users are configured")
+	}
+
+	// kept for compatibility, they never had any effect
+	if p.Expiration != "" {
+		report("exp is not supported, expiration is read from the token; use leeway or ignore_expiration")
+	}
+	if p.IsExpired {
+		report("is_expired is not supported, User.IsExpired is computed from the token exp")
+	}
+
+	return errors.Join(errs...)
+}
+
+// UserPrincipal is the authorization.Principal of the users built from tokens read with this config
+func (p ParserConfig) UserPrincipal() authorization.Principal {
+	if p.ServicePrincipal {
+		return authorization.PrincipalService
+	}
+	return authorization.PrincipalUser
+}
+
+// publicKey decodes the configured PEM public key or certificate, which must be usable with at least
+// one of the allowed algorithms
+func (p ParserConfig) publicKey() (interface{}, error) {
+	data := []byte(p.PublicKey)
+	if p.PublicKeyFile != "" {
+		var err error
+		if data, err = os.ReadFile(p.PublicKeyFile); err != nil {
+			return nil, fmt.Errorf("invalid public key: %v", err)
+		}
+	}
+
+	key, err := parsePublicKeyPEM(bytes.TrimSpace(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	usable := false
+	for _, alg := range p.allowedAlgorithms() {
+		usable = usable || supportedAlgorithms[alg](key)
+	}
+	if !usable {
+		return nil, fmt.Errorf("invalid public key: a %T key can't verify any of the allowed algorithms %v", key, p.allowedAlgorithms())
+	}
+	return key, nil
+}
@@ -0,0 +1,166 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	key := newRSAKey(t)
+	dir := t.TempDir()
+
+	// a self signed certificate of the signing key
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	certFile := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+
+	configFile := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(configFile, []byte(`{
+		"public_key_file": "`+certFile+`",
+		"issuers": ["https://idp.example.com/"],
+		"member_id_claim": ["https://xtg.com/member_id"]
+	}`), 0600))
+
+	c, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	require.NoError(t, c.Validate())
+	assert.Equal(t, []string{"https://idp.example.com/"}, c.Issuers)
+
+	p, err := NewParserE(c)
+	require.NoError(t, err)
+	claims := testClaims()
+	claims["iss"] = "https://idp.example.com/"
+	_, err = p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, key, "", claims))
+	assert.NoError(t, err)
+}
+
+func TestParseConfig_unknownField(t *testing.T) {
+	_, err := ParseConfig(strings.NewReader(`{"member_id_claims": ["sub"]}`))
+	assert.ErrorContains(t, err, "member_id_claims")
+
+	_, err = ParseConfig(strings.NewReader(`{"jwks": {"url": "https://idp.example.com/jwks", "refresh": "1h"}}`))
+	assert.ErrorContains(t, err, "refresh")
+}
+
+func TestParseConfig_durations(t *testing.T) {
+	c, err := ParseConfig(strings.NewReader(`{
+		"leeway": "30s",
+		"jwks": {"url": "https://idp.example.com/jwks", "refresh_interval": "1h", "timeout": 5000000000},
+		"client_config": {"fetcher_url": "https://api.example.com", "timeout": "2s", "breaker_cooldown": "1m30s"}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, c.Leeway)
+	assert.Equal(t, time.Hour, c.JWKS.RefreshInterval)
+	assert.Equal(t, 5*time.Second, c.JWKS.Timeout)
+	assert.Equal(t, 2*time.Second, c.ClientConfig.Timeout)
+	assert.Equal(t, 90*time.Second, c.ClientConfig.BreakerCooldown)
+
+	_, err = ParseConfig(strings.NewReader(`{"client_config": {"timeout": "2 seconds"}}`))
+	assert.ErrorContains(t, err, "client_config.timeout")
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	pub := publicKeyPEM(t, &newRSAKey(t).PublicKey)
+	t.Setenv("JWT_PUBLIC_KEY", strings.ReplaceAll(pub, "\n", `\n`))
+	t.Setenv("JWT_ALLOWED_ALGORITHMS", "RS256, PS256")
+	t.Setenv("JWT_MEMBER_ID_CLAIM", "https://xtg.com/member_id")
+	t.Setenv("JWT_FETCH_NEEDED_CLAIM", "https://xtg.com/fetch_needed")
+	t.Setenv("JWT_LEEWAY", "30s")
+	t.Setenv("JWT_IGNORE_EXPIRATION", "true")
+	t.Setenv("JWT_FETCHER_URL", "https://api.example.com/graphql")
+	t.Setenv("JWT_FETCHER_TIMEOUT", "2s")
+
+	c, err := LoadConfigEnv("JWT_")
+	require.NoError(t, err)
+	require.NoError(t, c.Validate())
+	assert.Equal(t, pub, c.PublicKey)
+	assert.Equal(t, []string{"RS256", "PS256"}, c.AllowedAlgorithms)
+	assert.Equal(t, 30*time.Second, c.Leeway)
+	assert.True(t, c.IgnoreExpiration)
+	assert.Equal(t, &ClientConfig{FetcherURL: "https://api.example.com/graphql", Timeout: 2 * time.Second}, c.ClientConfig)
+
+	t.Setenv("JWT_LEEWAY", "30")
+	t.Setenv("JWT_IGNORE_EXPIRATION", "maybe")
+	_, err = LoadConfigEnv("JWT_")
+	assert.ErrorContains(t, err, "JWT_LEEWAY")
+	assert.ErrorContains(t, err, "JWT_IGNORE_EXPIRATION")
+}
+
+func TestParserConfig_Validate(t *testing.T) {
+	pub := publicKeyPEM(t, &newRSAKey(t).PublicKey)
+	valid := func() ParserConfig {
+		c := testParserConfig()
+		c.PublicKey = pub
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *ParserConfig)
+		errs   []string
+	}{
+		{"valid", func(c *ParserConfig) {}, nil},
+		{"no key", func(c *ParserConfig) { c.PublicKey = "" }, []string{"one of public_key_str, public_key_file or jwks is required"}},
+		{"bad pem", func(c *ParserConfig) { c.PublicKey = "not a pem" }, []string{"invalid public key"}},
+		{"two keys", func(c *ParserConfig) { c.JWKS = &JWKSConfig{URL: "https://idp.example.com/jwks"} }, []string{"mutually exclusive"}},
+		{"jwks", func(c *ParserConfig) { c.PublicKey = ""; c.JWKS = &JWKSConfig{} }, []string{"jwks: exactly one of url or file is required"}},
+		{"algorithm", func(c *ParserConfig) { c.AllowedAlgorithms = []string{"RS256", "HS256"} }, []string{`unsupported algorithm "HS256"`}},
+		{"unusable key", func(c *ParserConfig) { c.AllowedAlgorithms = []string{"ES256"} }, []string{"can't verify any of the allowed algorithms"}},
+		{"member id", func(c *ParserConfig) { c.MemberIDClaim = nil }, []string{"member_id_claim is required"}},
+		{"empty claim", func(c *ParserConfig) { c.GroupsClaim = []string{""} }, []string{"groups_claim: empty claim name"}},
+		{"fetch needed without client", func(c *ParserConfig) { c.FetchNeededClaim = []string{"fetch"} }, []string{"fetch_needed_claim requires client_config"}},
+		{"unused client", func(c *ParserConfig) { c.ClientConfig = &ClientConfig{FetcherURL: "https://api.example.com"} }, nil},
+		{"fetch disabled", func(c *ParserConfig) {
+			c.FetchNeededClaim = []string{"fetch"}
+			c.ClientConfig = &ClientConfig{FetcherURL: "https://api.example.com"}
+			c.DisableFetchNeeded = true
+		}, nil},
+		{"fetcher url", func(c *ParserConfig) {
+			c.FetchNeededClaim = []string{"fetch"}
+			c.ClientConfig = &ClientConfig{FetcherURL: "api.example.com"}
+		}, []string{"invalid fetcher_url"}},
+		{"dummy", func(c *ParserConfig) { c.EnableDummyTokens = true }, []string{"neither dummy_token nor dummy_users"}},
+		{"deprecated", func(c *ParserConfig) { c.Expiration = "1h"; c.IsExpired = true }, []string{"exp is not supported", "is_expired is not supported"}},
+		{"several", func(c *ParserConfig) { c.PublicKey = ""; c.MemberIDClaim = nil; c.Leeway = -time.Second }, []string{
+			"public_key_file", "member_id_claim is required", "leeway must not be negative",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(&c)
+			err := c.Validate()
+			if tt.errs == nil {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, e := range tt.errs {
+				assert.ErrorContains(t, err, e)
+			}
+			var joined interface{ Unwrap() []error }
+			require.True(t, errors.As(err, &joined))
+			assert.Len(t, joined.Unwrap(), len(tt.errs))
+		})
+	}
+}
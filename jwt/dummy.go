@@ -0,0 +1,73 @@
+package jwt
+
+import (
+	"crypto/subtle"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+// DummyUser describes the User a static development token authenticates as
+type DummyUser struct {
+	MemberIDs []string `json:"member_ids"`
+	// Orgs is encoded in JSON like an organizations claim and Groups follows the format of a groups claim
+	Orgs      []authorization.Organization `json:"orgs"`
+	Groups    []interface{}                `json:"groups"`
+	TgxMember bool                         `json:"tgx_member"`
+	// AllowAll grants every permission, Groups is ignored. With neither AllowAll nor Groups every
+	// permission is denied
+	AllowAll bool `json:"allow_all"`
+	// ServicePrincipal marks the User as authorization.PrincipalService instead of PrincipalUser
+	ServicePrincipal bool `json:"service_principal"`
+}
+
+// dummyUser returns the User of a dummy token. Every configured token is compared in constant time, so
+// the response time doesn't tell how much of a token matched
+func (p *Parser) dummyUser(authorizationHeader, bearer string) (*authorization.User, bool, error) {
+	var match *DummyUser
+	if p.DummyToken != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(p.DummyToken)) == 1 {
+		// the legacy dummy token is a shared string, it's denied everything unless a DummyUsers entry
+		// describes it
+		match = &DummyUser{}
+	}
+	for token, d := range p.DummyUsers {
+		d := d
+		if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+			match = &d
+		}
+	}
+
+	if match == nil {
+		return nil, false, nil
+	}
+	if !p.EnableDummyTokens {
+		return nil, true, ErrDummyTokensDisabled
+	}
+
+	var permissions authorization.Permissions = authorization.DenyAllPermissions{}
+	switch {
+	case match.AllowAll:
+		permissions = authorization.AllowAllPermissions{}
+	case match.Groups != nil:
+		permissions = NewPermissions([]interface{}{match.Groups}, match.MemberIDs, p.AdminGroup)
+	}
+
+	memberIDs := match.MemberIDs
+	if memberIDs == nil {
+		memberIDs = []string{}
+	}
+
+	principal := authorization.PrincipalUser
+	if match.ServicePrincipal {
+		principal = authorization.PrincipalService
+	}
+
+	return &authorization.User{
+		AuthorizationValue: authorizationHeader,
+		IsDummy:            true,
+		Principal:          principal,
+		Permissions:        permissions,
+		UserID:             memberIDs,
+		Orgs:               match.Orgs,
+		TgxMember:          match.TgxMember,
+	}, true, nil
+}
@@ -0,0 +1,95 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+func dummyParser(enabled bool) *Parser {
+	c := testParserConfig()
+	c.DummyToken = "legacy"
+	c.EnableDummyTokens = enabled
+	c.DummyUsers = map[string]DummyUser{
+		"admin": {
+			MemberIDs: []string{"admin@xtg.com"},
+			Orgs:      []authorization.Organization{{Code: "tgx", Role: authorization.ADMIN}},
+			TgxMember: true,
+			AllowAll:  true,
+		},
+		"viewer": {
+			MemberIDs: []string{"viewer@xtg.com"},
+			Orgs:      []authorization.Organization{{Code: "org1", Role: authorization.VIEWER}},
+			Groups: []interface{}{map[string]interface{}{
+				"c": "org1", "t": "org", "p": map[string]interface{}{"hotelx": map[string]interface{}{"booking": []interface{}{"r1"}}},
+			}},
+		},
+		"nobody":  {MemberIDs: []string{"nobody@xtg.com"}},
+		"service": {MemberIDs: []string{"client-1"}, ServicePrincipal: true},
+	}
+	return NewParser(c)
+}
+
+func TestParser_dummyUsers(t *testing.T) {
+	p := dummyParser(true)
+
+	u, err := p.Parse("Bearer admin")
+	require.NoError(t, err)
+	assert.True(t, u.IsDummy)
+	assert.Equal(t, []string{"admin@xtg.com"}, u.UserID)
+	assert.Equal(t, authorization.PrincipalUser, u.Principal)
+	assert.True(t, u.IsTGXMemberRole(authorization.ADMIN, nil))
+	_, ok := u.Permissions.CheckPermission("any", "thing", authorization.Delete)
+	assert.True(t, ok)
+
+	u, err = p.Parse("Bearer viewer")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"org1"}, u.GetOrgs(authorization.VIEWER))
+	_, ok = u.Permissions.CheckPermission("hotelx", "booking", authorization.Read)
+	assert.True(t, ok)
+	_, ok = u.Permissions.CheckPermission("hotelx", "booking", authorization.Delete)
+	assert.False(t, ok)
+
+	u, err = p.Parse("Bearer nobody")
+	require.NoError(t, err)
+	_, ok = u.Permissions.CheckPermission("hotelx", "booking", authorization.Read)
+	assert.False(t, ok)
+
+	u, err = p.Parse("Bearer service")
+	require.NoError(t, err)
+	assert.Equal(t, authorization.PrincipalService, u.Principal)
+
+	// the legacy dummy token no longer has nil permissions, and is denied everything
+	u, err = p.Parse("Bearer legacy")
+	require.NoError(t, err)
+	assert.True(t, u.IsDummy)
+	require.NotNil(t, u.Permissions)
+	_, ok = u.Permissions.CheckPermission("any", "thing", authorization.Read)
+	assert.False(t, ok)
+}
+
+func TestParser_legacyDummyTokenAllowAll(t *testing.T) {
+	c := testParserConfig()
+	c.DummyToken = "legacy"
+	c.EnableDummyTokens = true
+	c.DummyUsers = map[string]DummyUser{"legacy": {AllowAll: true}}
+	p := NewParser(c)
+
+	u, err := p.Parse("Bearer legacy")
+	require.NoError(t, err)
+	_, ok := u.Permissions.CheckPermission("any", "thing", authorization.Delete)
+	assert.True(t, ok)
+}
+
+func TestParser_dummyTokensDisabled(t *testing.T) {
+	p := dummyParser(false)
+	for _, token := range []string{"admin", "legacy"} {
+		_, err := p.Parse("Bearer " + token)
+		assert.True(t, errors.Is(err, ErrDummyTokensDisabled), "unexpected error %v", err)
+	}
+	_, err := p.Parse("Bearer unknown")
+	assert.True(t, errors.Is(err, ErrMalformedToken))
+}
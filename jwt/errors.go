@@ -0,0 +1,149 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+
+	"github.com/form3tech-oss/jwt-go"
+)
+
+var (
+	// ErrMalformedHeader is returned when the Authorization header isn't a Bearer
+	ErrMalformedHeader = authorization.NewError("authorization header format must be Bearer {token}", authorization.ErrMalformedHeader)
+	// ErrMalformedToken is returned when the bearer isn't a well formed jwt
+	ErrMalformedToken = authorization.NewError("malformed token", authorization.ErrUnauthenticated)
+	// ErrInvalidSignature is returned when the token signature doesn't match the resolved key
+	ErrInvalidSignature = authorization.NewError("invalid token signature", authorization.ErrUnauthenticated)
+	// ErrUnsupportedAlgorithm is returned when the token alg isn't allowed or doesn't match the key
+	ErrUnsupportedAlgorithm = authorization.NewError("signing method not allowed", authorization.ErrUnauthenticated)
+	// ErrUnknownKey is returned when the token kid isn't in the key set
+	ErrUnknownKey = authorization.NewError("unknown signing key", authorization.ErrUnauthenticated)
+	// ErrKeySetUnavailable is returned when the key set can't be loaded
+	ErrKeySetUnavailable = authorization.NewError("key set unavailable", authorization.ErrUnavailable)
+	// ErrDummyTokensDisabled is returned for a dummy token when ParserConfig.EnableDummyTokens isn't set
+	ErrDummyTokensDisabled = authorization.NewError("dummy tokens are disabled", authorization.ErrUnauthenticated)
+	// ErrInvalidKey is returned by the parsers built with NewParser from an invalid public key
+	ErrInvalidKey = authorization.NewError("invalid public key", authorization.ErrUnavailable)
+	// ErrBearerUpgrade is returned when the full bearer fetched for a fetch needed token is empty, the
+	// short token itself, invalid, fetch needed too, or of another subject. It wraps the verification error
+	ErrBearerUpgrade = authorization.NewError("bearer upgrade failed", authorization.ErrUnauthenticated)
+	// ErrCircuitOpen is returned, wrapped in a FetchError, while the fetcher circuit breaker is open
+	ErrCircuitOpen = authorization.NewError("fetcher circuit breaker is open", authorization.ErrUnavailable)
+
+	// ErrInvalidIssuer is returned when the iss claim is missing or not in ParserConfig.Issuers
+	ErrInvalidIssuer = authorization.NewError("invalid issuer", authorization.ErrUnauthenticated)
+	// ErrUnknownIssuer is returned by MultiIssuerParser when no Parser is configured for the iss claim
+	ErrUnknownIssuer = authorization.NewError("unknown issuer", authorization.ErrUnauthenticated)
+	// ErrInvalidAudience is returned when no aud claim value is in ParserConfig.Audiences
+	ErrInvalidAudience = authorization.NewError("invalid audience", authorization.ErrUnauthenticated)
+	// ErrTokenExpired is returned when exp, plus the configured leeway, is in the past
+	ErrTokenExpired = authorization.NewError("token is expired", authorization.ErrUnauthenticated)
+	// ErrTokenNotValidYet is returned when nbf, minus the configured leeway, is in the future
+	ErrTokenNotValidYet = authorization.NewError("token is not valid yet", authorization.ErrUnauthenticated)
+	// ErrTokenUsedBeforeIssued is returned when iat, minus the configured leeway, is in the future
+	ErrTokenUsedBeforeIssued = authorization.NewError("token used before issued", authorization.ErrUnauthenticated)
+	// ErrMissingClaim is returned when a required claim is absent
+	ErrMissingClaim = authorization.NewError("missing claim", authorization.ErrUnauthenticated)
+	// ErrInvalidClaim is returned when a claim has an unexpected type
+	ErrInvalidClaim = authorization.NewError("invalid claim value", authorization.ErrUnauthenticated)
+)
+
+// ClaimError reports a claim that failed validation, Err is one of the sentinel errors of this package
+type ClaimError struct {
+	Claim string
+	Value interface{}
+	Err   error
+}
+
+func (e *ClaimError) Error() string {
+	if e.Value == nil {
+		return fmt.Sprintf("claim %s: %v", e.Claim, e.Err)
+	}
+	return fmt.Sprintf("claim %s %v: %v", e.Claim, e.Value, e.Err)
+}
+
+func (e *ClaimError) Unwrap() error {
+	return e.Err
+}
+
+// FetchError is returned when the full bearer of a fetch needed token can't be retrieved. It's
+// classified as ErrUnavailable when the cause is transient, as ErrUnauthenticated otherwise, e.g. when
+// the fetcher rejects the short token
+type FetchError struct {
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("error fetching bearer: %v", e.Err)
+}
+
+func (e *FetchError) Unwrap() []error {
+	if e.Temporary() {
+		return []error{authorization.ErrUnavailable, e.Err}
+	}
+	return []error{authorization.ErrUnauthenticated, e.Err}
+}
+
+// Temporary reports whether the fetch failed for a transient cause: a transport error, a timeout, a
+// cancellation, a retryable status or an unavailable dependency such as the open circuit breaker
+func (e *FetchError) Temporary() bool {
+	return retryable(e.Err) ||
+		errors.Is(e.Err, authorization.ErrUnavailable) ||
+		errors.Is(e.Err, context.DeadlineExceeded) ||
+		errors.Is(e.Err, context.Canceled)
+}
+
+// StatusError is returned, wrapped in a FetchError, when the fetcher answers with a non 2xx status
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected fetcher status: %s", e.Status)
+}
+
+// Temporary reports whether the request may succeed if retried
+func (e *StatusError) Temporary() bool {
+	switch {
+	case e.StatusCode == http.StatusRequestTimeout, e.StatusCode == http.StatusTooManyRequests:
+		return true
+	case e.StatusCode == http.StatusNotImplemented:
+		return false
+	default:
+		return e.StatusCode >= 500
+	}
+}
+
+// parseError classifies an error returned by the jwt library
+func (p *Parser) parseError(token *jwt.Token, err error) error {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		return fmt.Errorf("error parsing bearer: %w: %v", ErrMalformedToken, err)
+	}
+
+	// errors from KeyFunc are already classified
+	if ve.Inner != nil && (errors.Is(ve.Inner, authorization.ErrUnauthenticated) || errors.Is(ve.Inner, authorization.ErrUnavailable)) {
+		return fmt.Errorf("error parsing bearer: %w", ve.Inner)
+	}
+
+	class := ErrMalformedToken
+	switch {
+	case ve.Errors&jwt.ValidationErrorMalformed != 0:
+		class = ErrMalformedToken
+	case ve.Errors&jwt.ValidationErrorUnverifiable != 0 && ve.Inner != nil:
+		// a custom KeyFunc couldn't resolve the key
+		class = ErrUnknownKey
+	case ve.Errors&jwt.ValidationErrorUnverifiable != 0:
+		class = ErrUnsupportedAlgorithm
+	case token != nil && token.Method != nil && p.algorithms != nil && !authorization.Contains(p.algorithms, token.Method.Alg()):
+		class = ErrUnsupportedAlgorithm
+	case ve.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		class = ErrInvalidSignature
+	}
+	return fmt.Errorf("error parsing bearer: %w: %v", class, err)
+}
@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+func TestParser_errorClasses(t *testing.T) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	p, err := NewParserE(c)
+	require.NoError(t, err)
+
+	expired := testClaims()
+	expired["exp"] = float64(1)
+
+	tests := []struct {
+		name   string
+		header string
+		err    error
+		class  error
+	}{
+		{"malformed header", "Basic abc", ErrMalformedHeader, authorization.ErrMalformedHeader},
+		{"malformed token", "Bearer abc", ErrMalformedToken, authorization.ErrUnauthenticated},
+		{"invalid signature", "Bearer " + signToken(t, jwt.SigningMethodRS256, newRSAKey(t), "", testClaims()), ErrInvalidSignature, authorization.ErrUnauthenticated},
+		{"wrong algorithm", "Bearer " + signToken(t, jwt.SigningMethodRS512, key, "", testClaims()), ErrUnsupportedAlgorithm, authorization.ErrUnauthenticated},
+		{"unknown algorithm", "Bearer eyJhbGciOiJYWDEiLCJ0eXAiOiJKV1QifQ.e30.c2ln", ErrUnsupportedAlgorithm, authorization.ErrUnauthenticated},
+		{"expired", "Bearer " + signToken(t, jwt.SigningMethodRS256, key, "", expired), ErrTokenExpired, authorization.ErrUnauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Parse(tt.header)
+			assert.True(t, errors.Is(err, tt.err), "unexpected error %v", err)
+			assert.True(t, errors.Is(err, tt.class), "unexpected class %v", err)
+		})
+	}
+}
+
+func TestParser_keySetUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := testParserConfig()
+	c.JWKS = &JWKSConfig{URL: srv.URL}
+	_, err := NewParser(c).Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, newRSAKey(t), "k1", testClaims()))
+	assert.True(t, errors.Is(err, ErrKeySetUnavailable), "unexpected error %v", err)
+	assert.True(t, errors.Is(err, authorization.ErrUnavailable))
+}
+
+func TestFetchError(t *testing.T) {
+	cause := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	var err error = &FetchError{Err: cause}
+	assert.True(t, errors.Is(err, authorization.ErrUnavailable))
+	assert.False(t, errors.Is(err, authorization.ErrUnauthenticated))
+	assert.True(t, errors.Is(err, cause))
+
+	for _, cause := range []error{
+		&StatusError{StatusCode: http.StatusUnauthorized, Status: "401 Unauthorized"},
+		errors.New("token exchange response has no access_token"),
+	} {
+		err = &FetchError{Err: cause}
+		assert.True(t, errors.Is(err, authorization.ErrUnauthenticated), "unexpected class of %v", err)
+		assert.False(t, errors.Is(err, authorization.ErrUnavailable), "unexpected class of %v", err)
+	}
+}
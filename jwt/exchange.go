@@ -0,0 +1,97 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Token types and grant type defined by RFC 8693
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenExchangeConfig is the data of an RFC 8693 token exchange request
+type TokenExchangeConfig struct {
+	// ClientID and ClientSecret authenticate the exchange with HTTP basic auth when set
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Audience     string `json:"audience"`
+	Resource     string `json:"resource"`
+	Scope        string `json:"scope"`
+	// SubjectTokenType is the type of the short token. Defaults to TokenTypeAccessToken
+	SubjectTokenType string `json:"subject_token_type"`
+	// RequestedTokenType is sent when set, e.g. TokenTypeJWT
+	RequestedTokenType string `json:"requested_token_type"`
+}
+
+// TokenExchangeFetcher fetches the full token with an RFC 8693 token exchange of the short token
+type TokenExchangeFetcher struct {
+	// URL is the token endpoint
+	URL string
+	TokenExchangeConfig
+	// Headers are added to every request
+	Headers map[string]string
+	// Client sends the requests. Defaults to a client with a 10s timeout
+	Client *http.Client
+}
+
+var _ BearerFetcher = (*TokenExchangeFetcher)(nil)
+
+// FetchBearer exchanges shortToken and returns the issued access_token
+func (f *TokenExchangeFetcher) FetchBearer(ctx context.Context, shortToken string) (string, error) {
+	subjectTokenType := f.SubjectTokenType
+	if subjectTokenType == "" {
+		subjectTokenType = TokenTypeAccessToken
+	}
+	form := url.Values{
+		"grant_type":         {GrantTypeTokenExchange},
+		"subject_token":      {shortToken},
+		"subject_token_type": {subjectTokenType},
+	}
+	for k, v := range map[string]string{
+		"audience":             f.Audience,
+		"resource":             f.Resource,
+		"scope":                f.Scope,
+		"requested_token_type": f.RequestedTokenType,
+	} {
+		if v != "" {
+			form.Set(k, v)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", f.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	for k, v := range f.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if f.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(f.ClientID), url.QueryEscape(f.ClientSecret))
+	}
+
+	body, err := doFetch(f.Client, req)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		AccessToken     string `json:"access_token"`
+		IssuedTokenType string `json:"issued_token_type"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("decoding token exchange response: %v", err)
+	}
+	if response.AccessToken == "" {
+		return "", fmt.Errorf("token exchange response has no access_token")
+	}
+	return response.AccessToken, nil
+}
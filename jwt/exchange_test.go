@@ -0,0 +1,107 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTokenExchangeServer(t *testing.T, fullToken func() string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		require.NoError(t, r.ParseForm())
+		if !ok || id != "api" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, GrantTypeTokenExchange, r.PostForm.Get("grant_type"))
+		assert.Equal(t, TokenTypeAccessToken, r.PostForm.Get("subject_token_type"))
+		assert.Equal(t, "https://api.example.com", r.PostForm.Get("audience"))
+		assert.NotEmpty(t, r.PostForm.Get("subject_token"))
+		if r.PostForm.Get("subject_token") == "invalid" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token":      fullToken(),
+			"issued_token_type": TokenTypeAccessToken,
+			"token_type":        "Bearer",
+		})
+	}))
+}
+
+func TestTokenExchangeFetcher(t *testing.T) {
+	srv := newTokenExchangeServer(t, func() string { return "full" })
+	defer srv.Close()
+
+	f := &TokenExchangeFetcher{
+		URL:                 srv.URL,
+		TokenExchangeConfig: TokenExchangeConfig{ClientID: "api", ClientSecret: "s3cret", Audience: "https://api.example.com"},
+	}
+	bearer, err := f.FetchBearer(context.Background(), "short")
+	require.NoError(t, err)
+	assert.Equal(t, "full", bearer)
+
+	_, err = f.FetchBearer(context.Background(), "invalid")
+	var se *StatusError
+	require.True(t, errors.As(err, &se))
+	assert.Equal(t, http.StatusBadRequest, se.StatusCode)
+}
+
+func TestParser_tokenExchange(t *testing.T) {
+	var sign func(jwt.MapClaims) string
+	srv := newTokenExchangeServer(t, func() string { return sign(testClaims()) })
+	defer srv.Close()
+
+	p, signer := fetchNeededParser(t, srv.URL)
+	sign = signer
+	p.ClientConfig.TokenExchange = &TokenExchangeConfig{ClientID: "api", ClientSecret: "s3cret", Audience: "https://api.example.com"}
+	p, err := NewParserE(p.ParserConfig)
+	require.NoError(t, err)
+
+	u, err := p.Parse("Bearer " + sign(shortClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user@xtg.com"}, u.UserID)
+}
+
+type fetcherFunc func(ctx context.Context, shortToken string) (string, error)
+
+func (f fetcherFunc) FetchBearer(ctx context.Context, shortToken string) (string, error) {
+	return f(ctx, shortToken)
+}
+
+func TestParser_customFetcher(t *testing.T) {
+	p, sign := fetchNeededParser(t, "http://unused")
+	short := sign(shortClaims())
+	full := sign(testClaims())
+
+	config := p.ParserConfig
+	config.ClientConfig = nil
+	config.Fetcher = fetcherFunc(func(ctx context.Context, shortToken string) (string, error) {
+		assert.Equal(t, short, shortToken)
+		return full, nil
+	})
+	p, err := NewParserE(config)
+	require.NoError(t, err)
+	u, err := p.Parse("Bearer " + short)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer "+short, u.AuthorizationValue)
+
+	// replaced on the Parser
+	p, err = NewParserE(config)
+	require.NoError(t, err)
+	p.Fetcher = fetcherFunc(func(ctx context.Context, shortToken string) (string, error) {
+		return "", errors.New("down")
+	})
+	_, err = p.Parse("Bearer " + short)
+	var fetchErr *FetchError
+	assert.True(t, errors.As(err, &fetchErr))
+}
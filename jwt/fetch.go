@@ -0,0 +1,161 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+)
+
+const defaultFetchCacheSize = 1000
+
+// bearerCache coalesces concurrent fetches of the same short token into a single upstream call and
+// keeps the fetched full bearers until their exp. Cached bearers are verified on every use by the
+// Parser, the cache only saves the round trip to the fetcher
+type bearerCache struct {
+	size int
+	now  func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]cachedBearer
+	inflight map[string]*inflightFetch
+}
+
+// inflightFetch is a fetch shared by the callers waiting for the same key. It's cancelled when the last
+// of them stops waiting
+type inflightFetch struct {
+	done    chan struct{}
+	bearer  string
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+type cachedBearer struct {
+	bearer    string
+	expiresAt time.Time
+}
+
+// newBearerCache returns a cache holding up to size bearers, a non positive size only coalesces fetches
+func newBearerCache(size int, now func() time.Time) *bearerCache {
+	return &bearerCache{
+		size:     size,
+		now:      now,
+		entries:  map[string]cachedBearer{},
+		inflight: map[string]*inflightFetch{},
+	}
+}
+
+// get returns the cached bearer for key or the result of fetch. The fetch is shared by every caller
+// waiting for key, so it doesn't end with the ctx of the caller that started it: a caller whose ctx is
+// done stops waiting, and the fetch is cancelled once no caller waits for it
+func (c *bearerCache) get(ctx context.Context, key string, fetch func(ctx context.Context) (string, error)) (string, error) {
+	if bearer, ok := c.lookup(key); ok {
+		return bearer, nil
+	}
+
+	c.mu.Lock()
+	f, ok := c.inflight[key]
+	if !ok {
+		// the values of ctx, e.g. trace metadata, are kept
+		fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &inflightFetch{done: make(chan struct{}), cancel: cancel}
+		c.inflight[key] = f
+		go c.run(fetchCtx, key, f, fetch)
+	}
+	f.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.bearer, f.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			// later callers start a new fetch instead of joining the cancelled one
+			if c.inflight[key] == f {
+				delete(c.inflight, key)
+			}
+		}
+		c.mu.Unlock()
+		return "", ctx.Err()
+	}
+}
+
+func (c *bearerCache) run(ctx context.Context, key string, f *inflightFetch, fetch func(ctx context.Context) (string, error)) {
+	defer f.cancel()
+	bearer, err := fetch(ctx)
+	if err == nil {
+		c.store(key, bearer)
+	}
+
+	c.mu.Lock()
+	if c.inflight[key] == f {
+		delete(c.inflight, key)
+	}
+	c.mu.Unlock()
+
+	f.bearer, f.err = bearer, err
+	close(f.done)
+}
+
+// remove forgets the bearer of key, e.g. because it failed verification
+func (c *bearerCache) remove(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+func (c *bearerCache) lookup(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !c.now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return e.bearer, true
+}
+
+// store caches bearer until its exp, bearers without a readable exp aren't cached
+func (c *bearerCache) store(key, bearer string) {
+	if c.size <= 0 {
+		return
+	}
+	token, _, err := new(jwt.Parser).ParseUnverified(bearer, jwt.MapClaims{})
+	if err != nil {
+		return
+	}
+	exp, err := numericDateClaim(token.Claims.(jwt.MapClaims), "exp")
+	if err != nil || exp.IsZero() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if !now.Before(exp) {
+		return
+	}
+	if len(c.entries) >= c.size {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	// still full, make room dropping an arbitrary entry
+	for k := range c.entries {
+		if len(c.entries) < c.size {
+			break
+		}
+		delete(c.entries, k)
+	}
+	c.entries[key] = cachedBearer{bearer: bearer, expiresAt: exp}
+}
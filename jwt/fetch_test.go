@@ -0,0 +1,253 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_fetchNeededCoalesced(t *testing.T) {
+	var sign func(jwt.MapClaims) string
+	var calls int32
+	release := make(chan struct{})
+	srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return sign(testClaims()), true
+	})
+	defer srv.Close()
+	p, signer := fetchNeededParser(t, srv.URL)
+	sign = signer
+	short := "Bearer " + sign(shortClaims())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.Parse(short)
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// cached until the full bearer exp
+	u, err := p.Parse(short)
+	require.NoError(t, err)
+	assert.Equal(t, short, u.AuthorizationValue)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestParser_fetchNeededInvalidBearerNotCached(t *testing.T) {
+	var calls int32
+	other := newRSAKey(t)
+	srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		atomic.AddInt32(&calls, 1)
+		return signToken(t, jwt.SigningMethodRS256, other, "", testClaims()), true
+	})
+	defer srv.Close()
+	p, sign := fetchNeededParser(t, srv.URL)
+	short := "Bearer " + sign(shortClaims())
+
+	for i := 0; i < 2; i++ {
+		_, err := p.Parse(short)
+		assert.True(t, errors.Is(err, ErrInvalidSignature))
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+}
+
+func TestBearerCache(t *testing.T) {
+	key := newRSAKey(t)
+	now := time.Now()
+	c := newBearerCache(2, func() time.Time { return now })
+	bearer := func(exp time.Time) string {
+		return signToken(t, jwt.SigningMethodRS256, key, "", jwt.MapClaims{"exp": float64(exp.Unix())})
+	}
+	calls := 0
+	fetch := func(b string) func(context.Context) (string, error) {
+		return func(context.Context) (string, error) {
+			calls++
+			return b, nil
+		}
+	}
+	ctx := context.Background()
+
+	b1 := bearer(now.Add(time.Minute))
+	got, err := c.get(ctx, "a", fetch(b1))
+	require.NoError(t, err)
+	assert.Equal(t, b1, got)
+	got, _ = c.get(ctx, "a", fetch("unused"))
+	assert.Equal(t, b1, got)
+	assert.Equal(t, 1, calls)
+
+	// expired
+	now = now.Add(2 * time.Minute)
+	b2 := bearer(now.Add(time.Minute))
+	got, _ = c.get(ctx, "a", fetch(b2))
+	assert.Equal(t, b2, got)
+	assert.Equal(t, 2, calls)
+
+	// without exp the bearer isn't cached
+	c.get(ctx, "b", fetch("not a jwt"))
+	c.get(ctx, "b", fetch("not a jwt"))
+	assert.Equal(t, 4, calls)
+
+	// bounded
+	c.get(ctx, "c", fetch(bearer(now.Add(time.Minute))))
+	c.get(ctx, "d", fetch(bearer(now.Add(time.Minute))))
+	assert.Len(t, c.entries, 2)
+
+	// errors aren't cached
+	_, err = c.get(ctx, "e", func(context.Context) (string, error) { return "", errors.New("down") })
+	assert.Error(t, err)
+	got, _ = c.get(ctx, "e", fetch(b2))
+	assert.Equal(t, b2, got)
+}
+
+func TestParser_bearerUpgradeValidation(t *testing.T) {
+	withClaims := func(set map[string]interface{}) jwt.MapClaims {
+		claims := testClaims()
+		for k, v := range set {
+			claims[k] = v
+		}
+		return claims
+	}
+	short := withClaims(map[string]interface{}{fetchNeededClaim: true, "sub": "auth0|1"})
+
+	tests := []struct {
+		name  string
+		fetch func(sign func(jwt.MapClaims) string, short string) string
+		err   error
+	}{
+		{"empty", func(sign func(jwt.MapClaims) string, short string) string { return "" }, nil},
+		{"same token", func(sign func(jwt.MapClaims) string, short string) string { return short }, nil},
+		{"fetch needed loop", func(sign func(jwt.MapClaims) string, short string) string {
+			return sign(withClaims(map[string]interface{}{fetchNeededClaim: true, "sub": "auth0|1", "jti": "other"}))
+		}, nil},
+		{"other subject", func(sign func(jwt.MapClaims) string, short string) string {
+			return sign(withClaims(map[string]interface{}{"sub": "auth0|2"}))
+		}, nil},
+		{"other member", func(sign func(jwt.MapClaims) string, short string) string {
+			return sign(withClaims(map[string]interface{}{"sub": "auth0|1", "https://xtg.com/member_id": "other@xtg.com"}))
+		}, nil},
+		{"expired", func(sign func(jwt.MapClaims) string, short string) string {
+			return sign(withClaims(map[string]interface{}{"sub": "auth0|1", "exp": float64(time.Now().Add(-time.Hour).Unix())}))
+		}, ErrTokenExpired},
+		{"not a jwt", func(sign func(jwt.MapClaims) string, short string) string { return "garbage" }, ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sign func(jwt.MapClaims) string
+			var shortToken string
+			var calls int32
+			srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+				atomic.AddInt32(&calls, 1)
+				return tt.fetch(sign, shortToken), true
+			})
+			defer srv.Close()
+			p, signer := fetchNeededParser(t, srv.URL)
+			sign = signer
+			shortToken = sign(short)
+
+			_, err := p.Parse("Bearer " + shortToken)
+			assert.True(t, errors.Is(err, ErrBearerUpgrade), "unexpected error %v", err)
+			if tt.err != nil {
+				assert.True(t, errors.Is(err, tt.err), "unexpected error %v", err)
+			}
+			assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+		})
+	}
+
+	t.Run("short token without identity", func(t *testing.T) {
+		var sign func(jwt.MapClaims) string
+		var calls int32
+		srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+			atomic.AddInt32(&calls, 1)
+			return sign(testClaims()), true
+		})
+		defer srv.Close()
+		p, signer := fetchNeededParser(t, srv.URL)
+		sign = signer
+
+		anonymous := shortClaims()
+		delete(anonymous, "https://xtg.com/member_id")
+		_, err := p.Parse("Bearer " + sign(anonymous))
+		assert.True(t, errors.Is(err, ErrBearerUpgrade), "unexpected error %v", err)
+		assert.EqualValues(t, 0, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("same subject", func(t *testing.T) {
+		var sign func(jwt.MapClaims) string
+		srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+			return sign(withClaims(map[string]interface{}{"sub": "auth0|1"})), true
+		})
+		defer srv.Close()
+		p, signer := fetchNeededParser(t, srv.URL)
+		sign = signer
+		_, err := p.Parse("Bearer " + sign(short))
+		assert.NoError(t, err)
+	})
+}
+
+func TestParser_fetchNeededCancelledWithLastWaiter(t *testing.T) {
+	var calls int32
+	cancelled := make(chan struct{})
+	srv := newFetcherServer(func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		atomic.AddInt32(&calls, 1)
+		// the server notices the client went away once the body is read
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+		close(cancelled)
+		return "", false
+	})
+	defer srv.Close()
+	p, sign := fetchNeededParser(t, srv.URL)
+	short := "Bearer " + sign(shortClaims())
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	errs := make(chan error, 2)
+	for _, ctx := range []context.Context{ctx1, ctx2} {
+		go func(ctx context.Context) {
+			_, err := p.ParseContext(ctx, short)
+			errs <- err
+		}(ctx)
+	}
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, time.Millisecond)
+
+	// another caller still waits for the shared fetch
+	cancel1()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	select {
+	case <-cancelled:
+		t.Fatal("fetch cancelled while a caller waits for it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel2()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("fetch not cancelled once no caller waits for it")
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultGraphQLQuery        = "{ admin { jwt } }"
+	defaultGraphQLResponsePath = "data.admin.jwt"
+)
+
+// GraphQLFetcher fetches the full token with a GraphQL query authorized by the short token
+type GraphQLFetcher struct {
+	URL string
+	// Query is the GraphQL query returning the full token. Defaults to { admin { jwt } }
+	Query string
+	// ResponsePath is the dot separated path of the full token in the response. Defaults to data.admin.jwt
+	ResponsePath string
+	// Headers are added to every request
+	Headers map[string]string
+	// Client sends the requests. Defaults to a client with a 10s timeout
+	Client *http.Client
+}
+
+var _ BearerFetcher = (*GraphQLFetcher)(nil)
+
+// FetchBearer queries URL with the short token as Bearer
+func (f *GraphQLFetcher) FetchBearer(ctx context.Context, shortToken string) (string, error) {
+	query := f.Query
+	if query == "" {
+		query = defaultGraphQLQuery
+	}
+	payload, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", f.URL, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	for k, v := range f.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Authorization", "Bearer "+shortToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	body, err := doFetch(f.Client, req)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Data   interface{}              `json:"data"`
+		Errors []map[string]interface{} `json:"errors,omitempty"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("decoding fetcher response: %v", err)
+	}
+	if len(response.Errors) > 0 {
+		return "", fmt.Errorf("error fetching permissions data: %v", response.Errors)
+	}
+
+	path := f.ResponsePath
+	if path == "" {
+		path = defaultGraphQLResponsePath
+	}
+	return lookupPath(map[string]interface{}{"data": response.Data}, path)
+}
+
+// lookupPath returns the string at the dot separated path of v
+func lookupPath(v interface{}, path string) (string, error) {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("fetcher response has no %s", path)
+		}
+		v = m[key]
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("fetcher response has no %s", path)
+	}
+	return s, nil
+}
@@ -0,0 +1,51 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQLFetcher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "{ me { token } }", body["query"])
+		assert.Equal(t, "Bearer short", r.Header.Get("Authorization"))
+		assert.Equal(t, "tenant-a", r.Header.Get("X-Tenant"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"me": map[string]interface{}{"token": "full"}},
+		})
+	}))
+	defer srv.Close()
+
+	f := &GraphQLFetcher{
+		URL:          srv.URL,
+		Query:        "{ me { token } }",
+		ResponsePath: "data.me.token",
+		Headers:      map[string]string{"X-Tenant": "tenant-a", "Authorization": "ignored"},
+	}
+	bearer, err := f.FetchBearer(context.Background(), "short")
+	require.NoError(t, err)
+	assert.Equal(t, "full", bearer)
+
+	f.ResponsePath = "data.admin.jwt"
+	_, err = f.FetchBearer(context.Background(), "short")
+	assert.EqualError(t, err, "fetcher response has no data.admin.jwt")
+}
+
+func TestGraphQLFetcher_errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors": [{"message": "forbidden"}]}`))
+	}))
+	defer srv.Close()
+
+	_, err := (&GraphQLFetcher{URL: srv.URL}).FetchBearer(context.Background(), "short")
+	assert.Error(t, err)
+	assert.False(t, retryable(err))
+}
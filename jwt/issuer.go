@@ -0,0 +1,366 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+
+	"github.com/form3tech-oss/jwt-go"
+)
+
+const defaultIssuerTTL = time.Hour
+
+// IssuerConfig is the data required to instance an Issuer
+type IssuerConfig struct {
+	// PrivateKey signs the tokens, an *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey or any
+	// crypto.Signer of those public keys, such as a KMS or HSM backed signer
+	PrivateKey crypto.Signer
+	// Algorithm must be usable with PrivateKey. Defaults to DefaultAlgorithm
+	Algorithm string
+	// KeyID is set as the kid header when not empty
+	KeyID    string
+	Issuer   string
+	Audience []string
+	// TTL is the lifetime of the tokens. Defaults to 1h
+	TTL time.Duration
+	// Claims names the claims the tokens are written to, it should be the ParserConfig of the parsers
+	// reading them. The first name of every claim list is used
+	Claims ParserConfig
+	// Now is the clock setting iat and exp. Defaults to time.Now
+	Now func() time.Time
+}
+
+// Issuer signs tokens in the claim format Parser reads
+type Issuer struct {
+	config IssuerConfig
+	method jwt.SigningMethod
+}
+
+// TokenUser describes the User a token is issued for
+type TokenUser struct {
+	MemberID string
+	// Subject defaults to MemberID
+	Subject   string
+	ID        string
+	Scopes    []string
+	Groups    []Group
+	Orgs      []authorization.Organization
+	TGXMember bool
+	// FetchNeeded marks the token as a short token, see ClientConfig
+	FetchNeeded bool
+}
+
+// Group is a node of the group hierarchy of a groups claim
+type Group struct {
+	Code string
+	Type string
+	// Grants are the permissions of the group
+	Grants []Grant
+	// Additional are permissions on other groups, by group code
+	Additional map[string][]Grant
+	Groups     []Group
+}
+
+// Grant gives Permissions on an object of a product. Create, Read, Update and Delete are granted as
+// such, any other Permission must be a single character and is granted as a special permission
+type Grant struct {
+	Product     string
+	Object      string
+	Permissions []authorization.Permission
+}
+
+// NewIssuer returns an Issuer, the key must be usable with the algorithm and a member id claim is required
+func NewIssuer(c IssuerConfig) (*Issuer, error) {
+	if c.PrivateKey == nil {
+		return nil, fmt.Errorf("issuer: private key is required")
+	}
+	if c.Algorithm == "" {
+		c.Algorithm = DefaultAlgorithm
+	}
+	usable, ok := supportedAlgorithms[c.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("issuer: unsupported algorithm %q", c.Algorithm)
+	}
+	if !usable(c.PrivateKey.Public()) {
+		return nil, fmt.Errorf("issuer: a %T key can't sign %s", c.PrivateKey, c.Algorithm)
+	}
+	if len(c.Claims.MemberIDClaim) == 0 {
+		return nil, fmt.Errorf("issuer: member_id_claim is required")
+	}
+	if c.TTL <= 0 {
+		c.TTL = defaultIssuerTTL
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return &Issuer{config: c, method: jwt.GetSigningMethod(c.Algorithm)}, nil
+}
+
+// Issue returns a signed token for u
+func (i *Issuer) Issue(u TokenUser) (string, error) {
+	claims, err := i.Claims(u)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(i.method, claims)
+	if i.config.KeyID != "" {
+		token.Header["kid"] = i.config.KeyID
+	}
+	signingString, err := token.SigningString()
+	if err != nil {
+		return "", err
+	}
+	sig, err := signWith(i.config.PrivateKey, i.config.Algorithm, []byte(signingString))
+	if err != nil {
+		return "", fmt.Errorf("issuer: signing token: %v", err)
+	}
+	return signingString + "." + jwt.EncodeSegment(sig), nil
+}
+
+// signWith signs data for alg through the crypto.Signer interface, the library only signs with the
+// concrete key types
+func signWith(signer crypto.Signer, alg string, data []byte) ([]byte, error) {
+	if alg == SigningMethodEd25519.Alg() {
+		return signer.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	if !hash.Available() {
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS":
+		return signer.Sign(rand.Reader, digest, hash)
+	case "PS":
+		return signer.Sign(rand.Reader, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash})
+	case "ES":
+		// signers return ASN.1 signatures, JWS wants r and s padded to the size of the curve
+		der, err := signer.Sign(rand.Reader, digest, hash)
+		if err != nil {
+			return nil, err
+		}
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(der, &rs); err != nil {
+			return nil, fmt.Errorf("decoding ecdsa signature: %v", err)
+		}
+		size := (signer.Public().(*ecdsa.PublicKey).Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		rs.R.FillBytes(sig[:size])
+		rs.S.FillBytes(sig[size:])
+		return sig, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+// Claims returns the claims Issue signs for u
+func (i *Issuer) Claims(u TokenUser) (jwt.MapClaims, error) {
+	c := i.config.Claims
+	if u.MemberID == "" && !u.FetchNeeded {
+		return nil, fmt.Errorf("issuer: member id is required")
+	}
+
+	now := i.config.Now()
+	claims := jwt.MapClaims{
+		"iat": float64(now.Unix()),
+		"exp": float64(now.Add(i.config.TTL).Unix()),
+	}
+	set := func(name, v string) {
+		if v != "" {
+			claims[name] = v
+		}
+	}
+	set("iss", i.config.Issuer)
+	set("jti", u.ID)
+	set("scope", strings.Join(u.Scopes, " "))
+	set(c.MemberIDClaim[0], u.MemberID)
+	if u.Subject != "" {
+		claims["sub"] = u.Subject
+	} else {
+		set("sub", u.MemberID)
+	}
+	if len(i.config.Audience) > 0 {
+		aud := make([]interface{}, len(i.config.Audience))
+		for n, a := range i.config.Audience {
+			aud[n] = a
+		}
+		claims["aud"] = aud
+	}
+
+	if len(u.Groups) > 0 {
+		if len(c.GroupsClaim) == 0 {
+			return nil, fmt.Errorf("issuer: groups_claim is required to issue groups")
+		}
+		groups, err := groupsClaim(u.Groups)
+		if err != nil {
+			return nil, err
+		}
+		claims[c.GroupsClaim[0]] = groups
+	}
+	if len(u.Orgs) > 0 {
+		if len(c.OrganizationsClaim) == 0 {
+			return nil, fmt.Errorf("issuer: organizations_claim is required to issue orgs")
+		}
+		orgs, err := orgsClaim(u.Orgs)
+		if err != nil {
+			return nil, fmt.Errorf("issuer: %w", err)
+		}
+		claims[c.OrganizationsClaim[0]] = orgs
+	}
+	if u.TGXMember {
+		if len(c.TGXMemberClaim) == 0 {
+			return nil, fmt.Errorf("issuer: tgx_member_claim is required to issue tgx members")
+		}
+		claims[c.TGXMemberClaim[0]] = true
+	}
+	if u.FetchNeeded {
+		if len(c.FetchNeededClaim) == 0 {
+			return nil, fmt.Errorf("issuer: fetch_needed_claim is required to issue short tokens")
+		}
+		claims[c.FetchNeededClaim[0]] = true
+	}
+	return claims, nil
+}
+
+func groupsClaim(groups []Group) ([]interface{}, error) {
+	out := make([]interface{}, 0, len(groups))
+	for _, g := range groups {
+		if g.Code == "" {
+			return nil, fmt.Errorf("issuer: group code is required")
+		}
+		group := map[string]interface{}{
+			claimGroup: g.Code,
+			claimType:  g.Type,
+		}
+		if len(g.Grants) > 0 {
+			products, err := productsClaim(g.Grants)
+			if err != nil {
+				return nil, fmt.Errorf("issuer: group %s: %v", g.Code, err)
+			}
+			group[claimProducts] = products
+		}
+		if len(g.Additional) > 0 {
+			additional := make(map[string]interface{}, len(g.Additional))
+			for code, grants := range g.Additional {
+				products, err := productsClaim(grants)
+				if err != nil {
+					return nil, fmt.Errorf("issuer: group %s, additional %s: %v", g.Code, code, err)
+				}
+				additional[code] = products
+			}
+			group[claimAdditional] = additional
+		}
+		if len(g.Groups) > 0 {
+			children, err := groupsClaim(g.Groups)
+			if err != nil {
+				return nil, err
+			}
+			group[claimGroups] = children
+		}
+		out = append(out, group)
+	}
+	return out, nil
+}
+
+// productsClaim encodes grants as product -> object -> permission strings
+func productsClaim(grants []Grant) (map[string]interface{}, error) {
+	products := map[string]interface{}{}
+	for _, g := range grants {
+		perms, err := permissionString(g.Permissions)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %v", g.Product, g.Object, err)
+		}
+		objects, _ := products[g.Product].(map[string]interface{})
+		if objects == nil {
+			objects = map[string]interface{}{}
+			products[g.Product] = objects
+		}
+		roles, _ := objects[g.Object].([]interface{})
+		objects[g.Object] = append(roles, perms)
+	}
+	return products, nil
+}
+
+// permissionString is the inverse of extractPermissions: the crud flags, enabled with 1, followed
+// by the special permissions
+func permissionString(perms []authorization.Permission) (string, error) {
+	var crud, specials []string
+	for _, p := range perms {
+		switch p {
+		case authorization.Create, authorization.Read, authorization.Update, authorization.Delete:
+			crud = append(crud, string(p))
+		default:
+			if len([]rune(string(p))) != 1 || strings.ContainsAny(string(p), "01") {
+				return "", fmt.Errorf("invalid special permission %q", p)
+			}
+			specials = append(specials, string(p))
+		}
+	}
+	sort.Strings(crud)
+	s := strings.Join(crud, "")
+	if s != "" {
+		s += "1"
+	}
+	return s + strings.Join(specials, ""), nil
+}
+
+func orgsClaim(orgs []authorization.Organization) ([]interface{}, error) {
+	out := make([]interface{}, 0, len(orgs))
+	for _, o := range orgs {
+		claim, err := o.Claim()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, claim)
+	}
+	return out, nil
+}
+
+// ParsePrivateKeyPEM decodes a PKCS8, PKCS1 or SEC1 encoded private key
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
@@ -0,0 +1,198 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+func issuerTestConfig() ParserConfig {
+	return ParserConfig{
+		MemberIDClaim:      []string{"https://xtg.com/member_id"},
+		GroupsClaim:        []string{"https://xtg.com/iam"},
+		OrganizationsClaim: []string{"https://xtg.com/organization"},
+		TGXMemberClaim:     []string{"https://xtg.com/tgx_member"},
+		FetchNeededClaim:   []string{"https://xtg.com/fetch_needed"},
+	}
+}
+
+func TestIssuer_roundTrip(t *testing.T) {
+	key := newRSAKey(t)
+	now := time.Unix(time.Now().Unix(), 0)
+	config := issuerTestConfig()
+	issuer, err := NewIssuer(IssuerConfig{
+		PrivateKey: key,
+		KeyID:      "k1",
+		Issuer:     "https://idp.example.com/",
+		Claims:     config,
+		Now:        func() time.Time { return now },
+	})
+	require.NoError(t, err)
+
+	token, err := issuer.Issue(TokenUser{
+		MemberID:  "user@xtg.com",
+		ID:        "t1",
+		Scopes:    []string{"read", "write"},
+		TGXMember: true,
+		Groups: []Group{{
+			Code: "tgx",
+			Type: "root",
+			Grants: []Grant{
+				{Product: "iam", Object: "grp", Permissions: []authorization.Permission{authorization.Read, authorization.Update}},
+				{Product: "iam", Object: "grp", Permissions: []authorization.Permission{authorization.Execute}},
+			},
+			Additional: map[string][]Grant{
+				"other": {{Product: "hotelx", Object: "book", Permissions: []authorization.Permission{authorization.Create}}},
+			},
+			Groups: []Group{{Code: "child", Type: "org", Grants: []Grant{{Product: "hotelx", Object: "search", Permissions: []authorization.Permission{authorization.Read}}}}},
+		}},
+		Orgs: []authorization.Organization{
+			{Code: "tgx", Role: authorization.ADMIN},
+			{Code: "acme", Role: authorization.VIEWER, Services: []authorization.ServiceRole{{Service: authorization.BILLING, Role: authorization.OWNER}}},
+		},
+	})
+	require.NoError(t, err)
+
+	// the same token crafted by hand
+	handmade := signToken(t, jwt.SigningMethodRS256, key, "k1", jwt.MapClaims{
+		"iss":                        "https://idp.example.com/",
+		"sub":                        "user@xtg.com",
+		"jti":                        "t1",
+		"scope":                      "read write",
+		"iat":                        float64(now.Unix()),
+		"exp":                        float64(now.Add(time.Hour).Unix()),
+		"https://xtg.com/member_id":  "user@xtg.com",
+		"https://xtg.com/tgx_member": true,
+		"https://xtg.com/iam": []interface{}{map[string]interface{}{
+			"c": "tgx",
+			"t": "root",
+			"p": map[string]interface{}{"iam": map[string]interface{}{"grp": []interface{}{"ru1", "x"}}},
+			"a": map[string]interface{}{"other": map[string]interface{}{"hotelx": map[string]interface{}{"book": []interface{}{"c1"}}}},
+			"g": []interface{}{map[string]interface{}{
+				"c": "child",
+				"t": "org",
+				"p": map[string]interface{}{"hotelx": map[string]interface{}{"search": []interface{}{"r1"}}},
+			}},
+		}},
+		"https://xtg.com/organization": []interface{}{
+			map[string]interface{}{"o": "tgx", "r": "ADMIN"},
+			map[string]interface{}{"o": "acme", "r": "VIEWER", "s": []interface{}{map[string]interface{}{"c": "BILLING", "r": "OWNER"}}},
+		},
+	})
+
+	config.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	p, err := NewParserE(config)
+	require.NoError(t, err)
+	p.Now = func() time.Time { return now }
+
+	issued, err := p.Parse("Bearer " + token)
+	require.NoError(t, err)
+	expected, err := p.Parse("Bearer " + handmade)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Bearer "+token, issued.AuthorizationValue)
+	issued.AuthorizationValue = expected.AuthorizationValue
+	assert.Equal(t, expected, issued)
+
+	_, ok := issued.Permissions.CheckPermission("iam", "grp", authorization.Update)
+	assert.True(t, ok)
+	_, ok = issued.Permissions.CheckPermission("hotelx", "book", authorization.Create)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"acme"}, issued.GetOrgsServiceFilter(authorization.OWNER, &[]authorization.Service{authorization.BILLING}[0]))
+	assert.True(t, issued.IsTGXMemberRole(authorization.ADMIN, nil))
+}
+
+func TestIssuer_algorithms(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaKey := newRSAKey(t)
+	for _, tt := range []struct {
+		alg string
+		key crypto.Signer
+	}{
+		{"ES256", ecKey},
+		{"EdDSA", edKey},
+		{"PS256", rsaKey},
+		// signers of the supported keys, e.g. KMS or HSM backed ones
+		{"RS256", opaqueSigner{rsaKey}},
+		{"PS256", opaqueSigner{rsaKey}},
+		{"ES256", opaqueSigner{ecKey}},
+		{"EdDSA", opaqueSigner{edKey}},
+	} {
+		config := issuerTestConfig()
+		issuer, err := NewIssuer(IssuerConfig{PrivateKey: tt.key, Algorithm: tt.alg, Claims: config})
+		require.NoError(t, err, tt.alg)
+		token, err := issuer.Issue(TokenUser{MemberID: "user@xtg.com"})
+		require.NoError(t, err, tt.alg)
+
+		config.PublicKey = publicKeyPEM(t, tt.key.Public())
+		config.AllowedAlgorithms = []string{tt.alg}
+		u, err := NewParser(config).Parse("Bearer " + token)
+		require.NoError(t, err, "%s %T", tt.alg, tt.key)
+		assert.Equal(t, []string{"user@xtg.com"}, u.UserID)
+	}
+}
+
+// opaqueSigner hides the concrete type of a key, like a signer whose key never leaves a KMS
+type opaqueSigner struct {
+	crypto.Signer
+}
+
+func TestNewIssuer_invalid(t *testing.T) {
+	key := newRSAKey(t)
+	_, err := NewIssuer(IssuerConfig{PrivateKey: key, Algorithm: "ES256", Claims: issuerTestConfig()})
+	assert.Error(t, err)
+	_, err = NewIssuer(IssuerConfig{PrivateKey: key, Algorithm: "HS256", Claims: issuerTestConfig()})
+	assert.Error(t, err)
+	_, err = NewIssuer(IssuerConfig{PrivateKey: key})
+	assert.Error(t, err)
+
+	issuer, err := NewIssuer(IssuerConfig{PrivateKey: key, Claims: ParserConfig{MemberIDClaim: []string{"sub"}}})
+	require.NoError(t, err)
+	_, err = issuer.Issue(TokenUser{MemberID: "user@xtg.com", Groups: []Group{{Code: "g"}}})
+	assert.ErrorContains(t, err, "groups_claim")
+	_, err = issuer.Issue(TokenUser{})
+	assert.ErrorContains(t, err, "member id")
+
+	issuer, err = NewIssuer(IssuerConfig{PrivateKey: key, Claims: issuerTestConfig()})
+	require.NoError(t, err)
+	_, err = issuer.Issue(TokenUser{MemberID: "user@xtg.com", Groups: []Group{{
+		Code:   "g",
+		Grants: []Grant{{Product: "p", Object: "o", Permissions: []authorization.Permission{"1"}}},
+	}}})
+	assert.ErrorContains(t, err, "invalid special permission")
+
+	_, err = issuer.Issue(TokenUser{MemberID: "user@xtg.com", Orgs: []authorization.Organization{{Code: "org1", Role: 42}}})
+	assert.True(t, errors.Is(err, authorization.ErrUnknownRole))
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	key := newRSAKey(t)
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	for _, data := range [][]byte{pkcs1, pkcs8} {
+		signer, err := ParsePrivateKeyPEM(data)
+		require.NoError(t, err)
+		assert.True(t, key.Equal(signer))
+	}
+	_, err = ParsePrivateKeyPEM([]byte("not a pem"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,393 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+)
+
+const (
+	defaultJWKSRefreshInterval    = time.Hour
+	defaultJWKSMinRefreshInterval = time.Minute
+	defaultJWKSTimeout            = 10 * time.Second
+)
+
+// JWKSConfig is the data required to resolve verification keys from a JSON Web Key Set.
+// Exactly one of URL or File must be set
+type JWKSConfig struct {
+	// URL of the key set, usually the identity provider's jwks_uri
+	URL string `json:"url"`
+	// File is a local path holding the key set
+	File string `json:"file"`
+	// RefreshInterval is the maximum age of the key set before it is refreshed. Defaults to 1h
+	RefreshInterval time.Duration `json:"refresh_interval"`
+	// MinRefreshInterval limits how often an unknown kid can trigger a refresh, and how often a set that
+	// never loaded is retried. Defaults to 1m
+	MinRefreshInterval time.Duration `json:"min_refresh_interval"`
+	// Timeout of the http request fetching URL. Defaults to 10s
+	Timeout time.Duration `json:"timeout"`
+}
+
+// KeySet holds the keys of a JSON Web Key Set indexed by kid. The set is refreshed in the background
+// when it gets older than RefreshInterval, and when a token references an unknown kid. If a refresh
+// fails the last good set keeps being served
+type KeySet struct {
+	config JWKSConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+
+	// loading holds a token while the set is loaded, so a single load is in flight
+	loading chan struct{}
+}
+
+// NewKeySet returns a KeySet for the given config, keys are loaded lazily on first use
+func NewKeySet(c JWKSConfig) *KeySet {
+	if c.RefreshInterval <= 0 {
+		c.RefreshInterval = defaultJWKSRefreshInterval
+	}
+	if c.MinRefreshInterval <= 0 {
+		c.MinRefreshInterval = defaultJWKSMinRefreshInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultJWKSTimeout
+	}
+	return &KeySet{
+		config:  c,
+		client:  &http.Client{Timeout: c.Timeout},
+		now:     time.Now,
+		loading: make(chan struct{}, 1),
+	}
+}
+
+// KeyFunc resolves the verification key of a token from its kid header
+func (s *KeySet) KeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	return s.Key(kid)
+}
+
+// Key returns the key identified by kid. An empty kid is only accepted when the set holds a single key
+func (s *KeySet) Key(kid string) (interface{}, error) {
+	if !s.loaded() {
+		if err := s.loadFirst(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrKeySetUnavailable, err)
+		}
+	} else if s.stale() {
+		s.refreshInBackground()
+	}
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+
+	// unknown kid, the provider may have rotated its keys
+	if err := s.refresh(); err != nil {
+		return nil, fmt.Errorf("%w: key %q not found, refreshing key set: %v", ErrKeySetUnavailable, kid, err)
+	}
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+// Refresh forces a reload of the key set
+func (s *KeySet) Refresh() error {
+	s.loading <- struct{}{}
+	defer s.release()
+	return s.load(s.now())
+}
+
+// acquire takes the right to load the set, waiting up to Timeout for the load in flight
+func (s *KeySet) acquire() bool {
+	select {
+	case s.loading <- struct{}{}:
+		return true
+	default:
+	}
+	t := time.NewTimer(s.config.Timeout)
+	defer t.Stop()
+	select {
+	case s.loading <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+func (s *KeySet) release() {
+	<-s.loading
+}
+
+func (s *KeySet) lookup(kid string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if kid == "" {
+		if len(s.keys) != 1 {
+			return nil, false
+		}
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+func (s *KeySet) loaded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys != nil
+}
+
+func (s *KeySet) stale() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys == nil || s.now().Sub(s.fetchedAt) > s.config.RefreshInterval
+}
+
+// errKeySetLoading is returned to the callers that waited Timeout for a load in flight
+var errKeySetLoading = errors.New("jwks: timeout waiting for the key set to load")
+
+// loadFirst loads a set that never loaded. Callers wait for the attempt in flight, up to Timeout, and a
+// failed attempt is returned until MinRefreshInterval passed, so an unavailable provider isn't requested
+// once per token
+func (s *KeySet) loadFirst() error {
+	if !s.acquire() {
+		return errKeySetLoading
+	}
+	defer s.release()
+
+	s.mu.RLock()
+	loaded, lastAttempt, lastErr := s.keys != nil, s.lastAttempt, s.lastErr
+	s.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+	now := s.now()
+	if lastErr != nil && now.Sub(lastAttempt) < s.config.MinRefreshInterval {
+		return lastErr
+	}
+	return s.load(now)
+}
+
+// refresh reloads a loaded set, waiting up to Timeout for the load in flight. It's skipped when the last
+// attempt is more recent than MinRefreshInterval, which also covers another goroutine reloading it
+// meanwhile
+func (s *KeySet) refresh() error {
+	if !s.acquire() {
+		return errKeySetLoading
+	}
+	defer s.release()
+
+	now := s.now()
+	if s.throttled(now) {
+		return nil
+	}
+	return s.load(now)
+}
+
+// refreshInBackground reloads a stale set without blocking the caller, which keeps using the current
+// keys. It does nothing while another load is in flight. A failed refresh is tolerated as long as a
+// previous set is available
+func (s *KeySet) refreshInBackground() {
+	select {
+	case s.loading <- struct{}{}:
+	default:
+		return
+	}
+	now := s.now()
+	if s.throttled(now) {
+		s.release()
+		return
+	}
+	go func() {
+		defer s.release()
+		_ = s.load(now)
+	}()
+}
+
+func (s *KeySet) throttled(now time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !s.lastAttempt.IsZero() && now.Sub(s.lastAttempt) < s.config.MinRefreshInterval
+}
+
+func (s *KeySet) load(now time.Time) error {
+	s.mu.Lock()
+	s.lastAttempt = now
+	s.mu.Unlock()
+
+	keys, err := s.fetch()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	s.fetchedAt = now
+	return nil
+}
+
+func (s *KeySet) fetch() (map[string]interface{}, error) {
+	data, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+func (s *KeySet) read() ([]byte, error) {
+	switch {
+	case s.config.URL != "" && s.config.File != "":
+		return nil, fmt.Errorf("jwks: url and file are mutually exclusive")
+	case s.config.File != "":
+		return os.ReadFile(s.config.File)
+	case s.config.URL != "":
+		res, err := s.client.Get(s.config.URL)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("jwks: unexpected status fetching %s: %s", s.config.URL, res.Status)
+		}
+		return io.ReadAll(res.Body)
+	default:
+		return nil, fmt.Errorf("jwks: url or file required")
+	}
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS decodes a key set. Keys this package can't verify with are skipped: those not meant for
+// signatures, of an unsupported type or curve, or malformed. The set fails only when no key is left
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks: decoding key set: %v", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	var skipped []error
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("key %q: %v", k.Kid, err))
+			continue
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		if len(skipped) > 0 {
+			return nil, fmt.Errorf("jwks: no usable signing key: %w", errors.Join(skipped...))
+		}
+		return nil, fmt.Errorf("jwks: no usable signing key")
+	}
+	return keys, nil
+}
+
+// publicKey returns nil with no error for key types this package can't verify with
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %v", err)
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("exponent: %v", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x coordinate: %v", err)
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y coordinate: %v", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x coordinate: %v", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,271 @@
+package jwt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jwksServer struct {
+	*httptest.Server
+	keys     atomic.Value // []map[string]interface{}
+	requests int32
+	fail     int32
+}
+
+func newJWKSServer(keys ...map[string]interface{}) *jwksServer {
+	s := &jwksServer{}
+	s.keys.Store(keys)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+		if atomic.LoadInt32(&s.fail) == 1 {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys.Load()})
+	}))
+	return s
+}
+
+func TestParser_JWKS(t *testing.T) {
+	key := newRSAKey(t)
+	srv := newJWKSServer(rsaJWK("k1", &key.PublicKey))
+	defer srv.Close()
+
+	c := testParserConfig()
+	c.JWKS = &JWKSConfig{URL: srv.URL}
+	p := NewParser(c)
+
+	u, err := p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, key, "k1", testClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user@xtg.com"}, u.UserID)
+
+	_, err = p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, newRSAKey(t), "k1", testClaims()))
+	assert.Error(t, err)
+}
+
+func TestKeySet_rotationOnUnknownKid(t *testing.T) {
+	k1, k2 := newRSAKey(t), newRSAKey(t)
+	srv := newJWKSServer(rsaJWK("k1", &k1.PublicKey))
+	defer srv.Close()
+
+	ks := NewKeySet(JWKSConfig{URL: srv.URL, MinRefreshInterval: time.Nanosecond})
+	_, err := ks.Key("k1")
+	require.NoError(t, err)
+
+	srv.keys.Store([]map[string]interface{}{rsaJWK("k2", &k2.PublicKey)})
+	key, err := ks.Key("k2")
+	require.NoError(t, err)
+	assert.Equal(t, &k2.PublicKey, key)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&srv.requests))
+}
+
+func TestKeySet_unknownKidThrottled(t *testing.T) {
+	k1 := newRSAKey(t)
+	srv := newJWKSServer(rsaJWK("k1", &k1.PublicKey))
+	defer srv.Close()
+
+	ks := NewKeySet(JWKSConfig{URL: srv.URL, MinRefreshInterval: time.Hour})
+	for i := 0; i < 5; i++ {
+		_, err := ks.Key("unknown")
+		assert.Error(t, err)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&srv.requests))
+}
+
+func TestKeySet_keepsLastGoodSet(t *testing.T) {
+	k1 := newRSAKey(t)
+	srv := newJWKSServer(rsaJWK("k1", &k1.PublicKey))
+	defer srv.Close()
+
+	now := time.Now()
+	ks := NewKeySet(JWKSConfig{URL: srv.URL, RefreshInterval: time.Minute})
+	ks.now = func() time.Time { return now }
+	_, err := ks.Key("k1")
+	require.NoError(t, err)
+
+	atomic.StoreInt32(&srv.fail, 1)
+	now = now.Add(2 * time.Minute)
+	key, err := ks.Key("k1")
+	require.NoError(t, err)
+	assert.Equal(t, &k1.PublicKey, key)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&srv.requests) == 2 }, time.Second, time.Millisecond)
+
+	ks.Refresh()
+	key, err = ks.Key("k1")
+	require.NoError(t, err)
+	assert.Equal(t, &k1.PublicKey, key)
+}
+
+func TestKeySet_periodicRefresh(t *testing.T) {
+	k1, k2 := newRSAKey(t), newRSAKey(t)
+	srv := newJWKSServer(rsaJWK("k1", &k1.PublicKey))
+	defer srv.Close()
+
+	now := time.Now()
+	ks := NewKeySet(JWKSConfig{URL: srv.URL, RefreshInterval: time.Minute})
+	ks.now = func() time.Time { return now }
+	key, err := ks.Key("k1")
+	require.NoError(t, err)
+	assert.Equal(t, &k1.PublicKey, key)
+
+	// same kid, new key material, the stale key is served while the set is refreshed
+	srv.keys.Store([]map[string]interface{}{rsaJWK("k1", &k2.PublicKey)})
+	now = now.Add(2 * time.Minute)
+	key, err = ks.Key("k1")
+	require.NoError(t, err)
+	assert.Equal(t, &k1.PublicKey, key)
+	require.Eventually(t, func() bool {
+		key, err := ks.Key("k1")
+		return err == nil && assert.ObjectsAreEqual(&k2.PublicKey, key)
+	}, time.Second, time.Millisecond)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&srv.requests))
+}
+
+func TestKeySet_staleRefreshDoesntBlock(t *testing.T) {
+	k1 := newRSAKey(t)
+	release := make(chan struct{})
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{rsaJWK("k1", &k1.PublicKey)}})
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	now := time.Now()
+	ks := NewKeySet(JWKSConfig{URL: srv.URL, RefreshInterval: time.Minute})
+	ks.now = func() time.Time { return now }
+	_, err := ks.Key("k1")
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 5; i++ {
+		key, err := ks.Key("k1")
+		require.NoError(t, err)
+		assert.Equal(t, &k1.PublicKey, key)
+	}
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&requests) == 2 }, time.Second, time.Millisecond)
+}
+
+func TestKeySet_file(t *testing.T) {
+	k1 := newRSAKey(t)
+	b, err := json.Marshal(map[string]interface{}{"keys": []interface{}{rsaJWK("k1", &k1.PublicKey)}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+
+	ks := NewKeySet(JWKSConfig{File: path})
+	key, err := ks.Key("")
+	require.NoError(t, err)
+	assert.Equal(t, &k1.PublicKey, key)
+}
+
+func TestKeySet_failedFirstLoadThrottled(t *testing.T) {
+	k1 := newRSAKey(t)
+	srv := newJWKSServer(rsaJWK("k1", &k1.PublicKey))
+	defer srv.Close()
+	atomic.StoreInt32(&srv.fail, 1)
+
+	now := time.Now()
+	ks := NewKeySet(JWKSConfig{URL: srv.URL, MinRefreshInterval: time.Minute})
+	ks.now = func() time.Time { return now }
+	for i := 0; i < 5; i++ {
+		_, err := ks.Key("k1")
+		assert.ErrorIs(t, err, ErrKeySetUnavailable)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&srv.requests))
+
+	atomic.StoreInt32(&srv.fail, 0)
+	now = now.Add(2 * time.Minute)
+	key, err := ks.Key("k1")
+	require.NoError(t, err)
+	assert.Equal(t, &k1.PublicKey, key)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&srv.requests))
+}
+
+func TestKeySet_firstLoadInFlight(t *testing.T) {
+	k1 := newRSAKey(t)
+	release := make(chan struct{})
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{rsaJWK("k1", &k1.PublicKey)}})
+	}))
+	defer srv.Close()
+
+	ks := NewKeySet(JWKSConfig{URL: srv.URL})
+	first := make(chan error, 1)
+	go func() {
+		_, err := ks.Key("k1")
+		first <- err
+	}()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&requests) == 1 }, time.Second, time.Millisecond)
+
+	// waits for the load in flight
+	second := make(chan error, 1)
+	go func() {
+		_, err := ks.Key("k1")
+		second <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	require.NoError(t, <-first)
+	require.NoError(t, <-second)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+}
+
+func TestKeySet_firstLoadWaitBounded(t *testing.T) {
+	release := make(chan struct{})
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ks := NewKeySet(JWKSConfig{URL: srv.URL, Timeout: 50 * time.Millisecond})
+	// holds the load in flight past the timeout of the waiters
+	ks.client.Timeout = time.Minute
+	go ks.Key("k1")
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&requests) == 1 }, time.Second, time.Millisecond)
+
+	_, err := ks.Key("k1")
+	assert.ErrorIs(t, err, ErrKeySetUnavailable)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+}
+
+func TestParseJWKS_skipsUnusableKeys(t *testing.T) {
+	k1 := newRSAKey(t)
+	set := func(keys ...interface{}) []byte {
+		b, err := json.Marshal(map[string]interface{}{"keys": keys})
+		require.NoError(t, err)
+		return b
+	}
+	secp256k1 := map[string]interface{}{"kty": "EC", "kid": "k2", "crv": "secp256k1", "x": "AQ", "y": "AQ"}
+	encryption := map[string]interface{}{"kty": "RSA", "kid": "k3", "use": "enc", "n": "AQ", "e": "AQAB"}
+	malformed := map[string]interface{}{"kty": "RSA", "kid": "k4"}
+	symmetric := map[string]interface{}{"kty": "oct", "kid": "k5", "k": "AQ"}
+
+	keys, err := parseJWKS(set(secp256k1, rsaJWK("k1", &k1.PublicKey), encryption, malformed, symmetric))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"k1": &k1.PublicKey}, keys)
+
+	_, err = parseJWKS(set(secp256k1, malformed))
+	assert.ErrorContains(t, err, "secp256k1")
+	_, err = parseJWKS(set())
+	assert.Error(t, err)
+}
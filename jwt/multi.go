@@ -0,0 +1,72 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+
+	"github.com/form3tech-oss/jwt-go"
+)
+
+var (
+	_ authorization.Parser        = (*MultiIssuerParser)(nil)
+	_ authorization.ContextParser = (*MultiIssuerParser)(nil)
+)
+
+// MultiIssuerParser routes every bearer to the Parser configured for its iss claim. The iss claim is
+// read before the signature is verified only to pick the Parser, which then verifies the token with
+// its own keys and only accepts its own issuer
+type MultiIssuerParser struct {
+	parsers map[string]*Parser
+}
+
+// NewMultiIssuerParser returns a MultiIssuerParser from the ParserConfig of each issuer. Issuers of
+// each config is overwritten with its key
+func NewMultiIssuerParser(configs map[string]ParserConfig) (*MultiIssuerParser, error) {
+	parsers := make(map[string]*Parser, len(configs))
+	for iss, c := range configs {
+		if iss == "" {
+			return nil, fmt.Errorf("issuer can't be empty")
+		}
+		c.Issuers = []string{iss}
+		p, err := NewParserE(c)
+		if err != nil {
+			return nil, fmt.Errorf("issuer %s: %w", iss, err)
+		}
+		parsers[iss] = p
+	}
+	return &MultiIssuerParser{parsers: parsers}, nil
+}
+
+// Parser returns the Parser of an issuer, e.g. to set its Revoker or clock
+func (m *MultiIssuerParser) Parser(iss string) (*Parser, bool) {
+	p, ok := m.parsers[iss]
+	return p, ok
+}
+
+func (m *MultiIssuerParser) Parse(authorizationHeader string) (*authorization.User, error) {
+	return m.ParseContext(context.Background(), authorizationHeader)
+}
+
+func (m *MultiIssuerParser) ParseContext(ctx context.Context, authorizationHeader string) (*authorization.User, error) {
+	scheme, bearer, ok := strings.Cut(authorizationHeader, " ")
+	if !ok || scheme != "Bearer" {
+		return nil, ErrMalformedHeader
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(bearer, claims); err != nil {
+		return nil, fmt.Errorf("error parsing bearer: %w: %v", ErrMalformedToken, err)
+	}
+	iss, ok := claims["iss"].(string)
+	if !ok {
+		return nil, &ClaimError{Claim: "iss", Value: claims["iss"], Err: ErrUnknownIssuer}
+	}
+	p, ok := m.parsers[iss]
+	if !ok {
+		return nil, &ClaimError{Claim: "iss", Value: iss, Err: ErrUnknownIssuer}
+	}
+	return p.ParseContext(ctx, authorizationHeader)
+}
@@ -0,0 +1,60 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiIssuerParser(t *testing.T) {
+	keyA, keyB := newRSAKey(t), newRSAKey(t)
+	configA := testParserConfig()
+	configA.PublicKey = publicKeyPEM(t, &keyA.PublicKey)
+	configB := ParserConfig{
+		PublicKey:     publicKeyPEM(t, &keyB.PublicKey),
+		MemberIDClaim: []string{"https://tenant-b.com/member"},
+	}
+
+	p, err := NewMultiIssuerParser(map[string]ParserConfig{
+		"https://a.com/": configA,
+		"https://b.com/": configB,
+	})
+	require.NoError(t, err)
+
+	claimsA := testClaims()
+	claimsA["iss"] = "https://a.com/"
+	u, err := p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, keyA, "", claimsA))
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.com/", u.Issuer)
+	assert.Equal(t, []string{"user@xtg.com"}, u.UserID)
+
+	claimsB := jwt.MapClaims{"iss": "https://b.com/", "exp": claimsA["exp"], "https://tenant-b.com/member": "b@b.com"}
+	u, err = p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, keyB, "", claimsB))
+	require.NoError(t, err)
+	assert.Equal(t, "https://b.com/", u.Issuer)
+	assert.Equal(t, []string{"b@b.com"}, u.UserID)
+
+	// issuer a claiming to be b is verified with b's key
+	claimsA["iss"] = "https://b.com/"
+	_, err = p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, keyA, "", claimsA))
+	assert.True(t, errors.Is(err, ErrInvalidSignature), "unexpected error %v", err)
+
+	claimsA["iss"] = "https://c.com/"
+	_, err = p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, keyA, "", claimsA))
+	assert.True(t, errors.Is(err, ErrUnknownIssuer))
+
+	delete(claimsA, "iss")
+	_, err = p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, keyA, "", claimsA))
+	assert.True(t, errors.Is(err, ErrUnknownIssuer))
+
+	_, err = p.Parse("Bearer garbage")
+	assert.True(t, errors.Is(err, ErrMalformedToken))
+}
+
+func TestNewMultiIssuerParser_invalidKey(t *testing.T) {
+	_, err := NewMultiIssuerParser(map[string]ParserConfig{"https://a.com/": {PublicKey: "bad"}})
+	assert.Error(t, err)
+}
@@ -253,8 +253,12 @@ func getObjects(v interface{}, group string, p map[authorization.Permission]map[
 	// Iterate through each role of the object
 	if roles, ok := v.([]interface{}); ok {
 		for _, rol := range roles {
+			rolStr, ok := rol.(string)
+			if !ok {
+				continue
+			}
 			// Extract role permissions and store them
-			for _, permissions := range extractPermissions(rol.(string)) {
+			for _, permissions := range extractPermissions(rolStr) {
 				if o[permissions] == nil {
 					o[permissions] = make(map[string]struct{})
 				}
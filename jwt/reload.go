@@ -0,0 +1,182 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+var (
+	_ authorization.Parser        = (*ReloadableParser)(nil)
+	_ authorization.ContextParser = (*ReloadableParser)(nil)
+)
+
+// ConfigSource returns the current ParserConfig, e.g. LoadConfig of a mounted file
+type ConfigSource func() (ParserConfig, error)
+
+// FileConfigSource reads the config with LoadConfig(path)
+func FileConfigSource(path string) ConfigSource {
+	return func() (ParserConfig, error) {
+		return LoadConfig(path)
+	}
+}
+
+// EnvConfigSource reads the config with LoadConfigEnv(prefix)
+func EnvConfigSource(prefix string) ConfigSource {
+	return func() (ParserConfig, error) {
+		return LoadConfigEnv(prefix)
+	}
+}
+
+// ReloadableParser is a Parser whose ParserConfig is polled from a ConfigSource. A new Parser is built
+// when the config, or the content of its PublicKeyFile, changes and swapped in once it validates; when
+// the source can't be read or the new config is invalid the active Parser keeps being used
+type ReloadableParser struct {
+	source ConfigSource
+	setup  func(*Parser)
+	active atomic.Pointer[versionedParser]
+
+	mu      sync.Mutex
+	lastErr error
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type versionedParser struct {
+	parser  *Parser
+	version string
+}
+
+// NewReloadableParser loads the config from source and polls it every interval until Close is called.
+// setup, when not nil, is applied to every Parser built, e.g. to set its Revoker or Fetcher
+func NewReloadableParser(source ConfigSource, interval time.Duration, setup func(*Parser)) (*ReloadableParser, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("jwt: polling interval must be positive")
+	}
+	r := &ReloadableParser{
+		source: source,
+		setup:  setup,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	go r.poll(interval)
+	return r, nil
+}
+
+func (r *ReloadableParser) Parse(authorizationHeader string) (*authorization.User, error) {
+	return r.ParseContext(context.Background(), authorizationHeader)
+}
+
+// ParseContext parses the header with the active Parser
+func (r *ReloadableParser) ParseContext(ctx context.Context, authorizationHeader string) (*authorization.User, error) {
+	return r.Parser().ParseContext(ctx, authorizationHeader)
+}
+
+// Parser returns the active Parser
+func (r *ReloadableParser) Parser() *Parser {
+	return r.active.Load().parser
+}
+
+// Version identifies the active config, it is a hash of the config and of its public key file
+func (r *ReloadableParser) Version() string {
+	return r.active.Load().version
+}
+
+// Reload reads the source, the Parser is only replaced when the config changed and is valid
+func (r *ReloadableParser) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.reload()
+	if err != nil {
+		err = fmt.Errorf("jwt: reloading parser config: %w", err)
+	}
+	r.lastErr = err
+	return err
+}
+
+func (r *ReloadableParser) reload() error {
+	c, err := r.source()
+	if err != nil {
+		return err
+	}
+	version, err := configVersion(c)
+	if err != nil {
+		return err
+	}
+	if active := r.active.Load(); active != nil && active.version == version {
+		return nil
+	}
+
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	p, err := NewParserE(c)
+	if err != nil {
+		return err
+	}
+	if r.setup != nil {
+		r.setup(p)
+	}
+	r.active.Store(&versionedParser{parser: p, version: version})
+	return nil
+}
+
+// Err returns the error of the last reload, if any
+func (r *ReloadableParser) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastErr
+}
+
+// Close stops polling the source, it's safe to call more than once
+func (r *ReloadableParser) Close() {
+	r.closeOnce.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+func (r *ReloadableParser) poll(interval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.Reload()
+		}
+	}
+}
+
+// configVersion hashes the config and the content of its public key file, a key rotated in place
+// changes the version
+func configVersion(c ParserConfig) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(data)
+	if c.PublicKeyFile != "" {
+		key, err := os.ReadFile(c.PublicKeyFile)
+		if err != nil {
+			return "", fmt.Errorf("invalid public key: %v", err)
+		}
+		h.Write(key)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
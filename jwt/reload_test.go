@@ -0,0 +1,124 @@
+package jwt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+func writeReloadConfig(t *testing.T, dir string, memberIDClaim string) string {
+	configFile := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(configFile, []byte(`{
+		"public_key_file": "`+filepath.Join(dir, "key.pem")+`",
+		"member_id_claim": ["`+memberIDClaim+`"]
+	}`), 0600))
+	return configFile
+}
+
+func TestReloadableParser(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key.pem")
+	oldKey, newKey := newRSAKey(t), newRSAKey(t)
+	require.NoError(t, os.WriteFile(keyFile, []byte(publicKeyPEM(t, &oldKey.PublicKey)), 0600))
+	configFile := writeReloadConfig(t, dir, "https://xtg.com/member_id")
+
+	setups := 0
+	r, err := NewReloadableParser(FileConfigSource(configFile), time.Hour, func(p *Parser) {
+		setups++
+		p.Revoker = revokerFunc(func(u *authorization.User) (bool, error) { return false, nil })
+	})
+	require.NoError(t, err)
+	defer r.Close()
+	version := r.Version()
+	assert.NotEmpty(t, version)
+	assert.NotNil(t, r.Parser().Revoker)
+
+	_, err = r.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, oldKey, "", testClaims()))
+	assert.NoError(t, err)
+
+	// unchanged source
+	require.NoError(t, r.Reload())
+	assert.Equal(t, version, r.Version())
+	assert.Equal(t, 1, setups)
+
+	// key rotated in place
+	require.NoError(t, os.WriteFile(keyFile, []byte(publicKeyPEM(t, &newKey.PublicKey)), 0600))
+	require.NoError(t, r.Reload())
+	assert.NotEqual(t, version, r.Version())
+	assert.Equal(t, 2, setups)
+	_, err = r.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, oldKey, "", testClaims()))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = r.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, newKey, "", testClaims()))
+	assert.NoError(t, err)
+
+	// an invalid config keeps the active parser
+	version = r.Version()
+	writeReloadConfig(t, dir, "")
+	assert.Error(t, r.Reload())
+	assert.Error(t, r.Err())
+	assert.Equal(t, version, r.Version())
+	_, err = r.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, newKey, "", testClaims()))
+	assert.NoError(t, err)
+
+	writeReloadConfig(t, dir, "https://xtg.com/member_id")
+	assert.NoError(t, r.Reload())
+	assert.NoError(t, r.Err())
+
+	// closed again by the deferred call
+	r.Close()
+}
+
+func TestReloadableParser_polling(t *testing.T) {
+	dir := t.TempDir()
+	key := newRSAKey(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "key.pem"), []byte(publicKeyPEM(t, &key.PublicKey)), 0600))
+	configFile := writeReloadConfig(t, dir, "https://xtg.com/member_id")
+
+	r, err := NewReloadableParser(FileConfigSource(configFile), 10*time.Millisecond, nil)
+	require.NoError(t, err)
+	defer r.Close()
+
+	writeReloadConfig(t, dir, "sub")
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"sub"}, r.Parser().MemberIDClaim)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNewReloadableParser_invalid(t *testing.T) {
+	_, err := NewReloadableParser(FileConfigSource(filepath.Join(t.TempDir(), "missing.json")), time.Hour, nil)
+	assert.Error(t, err)
+	_, err = NewReloadableParser(FileConfigSource("unused"), 0, nil)
+	assert.Error(t, err)
+}
+
+func TestReloadableParser_disableFetchNeeded(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "key.pem"), []byte(publicKeyPEM(t, &newRSAKey(t).PublicKey)), 0600))
+	configFile := filepath.Join(dir, "config.json")
+	write := func(disabled string) {
+		require.NoError(t, os.WriteFile(configFile, []byte(`{
+			"public_key_file": "`+filepath.Join(dir, "key.pem")+`",
+			"member_id_claim": ["https://xtg.com/member_id"],
+			"fetch_needed_claim": ["https://xtg.com/fetch_needed"],
+			"client_config": {"fetcher_url": "https://api.example.com/graphql"},
+			"disable_fetch_needed": `+disabled+`
+		}`), 0600))
+	}
+
+	write("false")
+	r, err := NewReloadableParser(FileConfigSource(configFile), time.Hour, nil)
+	require.NoError(t, err)
+	defer r.Close()
+	assert.False(t, r.Parser().DisableFetchNeeded)
+
+	// fetching is switched off without removing the client
+	write("true")
+	require.NoError(t, r.Reload())
+	assert.True(t, r.Parser().DisableFetchNeeded)
+}
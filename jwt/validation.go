@@ -0,0 +1,52 @@
+package jwt
+
+import (
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+// validateClaims checks the registered claims the token is restricted by ParserConfig
+func (p *Parser) validateClaims(claims *Claims) error {
+	if err := p.validateTime(claims); err != nil {
+		return err
+	}
+
+	return p.ValidateIssuerAndAudience(claims)
+}
+
+// ValidateIssuerAndAudience checks the iss and aud claims against Issuers and Audiences, any value is
+// accepted when they're empty
+func (c ParserConfig) ValidateIssuerAndAudience(claims *Claims) error {
+	if len(c.Issuers) > 0 && !authorization.Contains(c.Issuers, claims.Issuer) {
+		return &ClaimError{Claim: "iss", Value: claims.Raw["iss"], Err: ErrInvalidIssuer}
+	}
+
+	if len(c.Audiences) > 0 && !containsAny(c.Audiences, claims.Audience) {
+		return &ClaimError{Claim: "aud", Value: claims.Raw["aud"], Err: ErrInvalidAudience}
+	}
+	return nil
+}
+
+// validateTime checks exp, nbf and iat against the parser clock, tolerating Leeway in every direction
+func (p *Parser) validateTime(claims *Claims) error {
+	now := p.now()
+
+	if !p.IgnoreExpiration && !now.Before(claims.ExpiresAt.Add(p.Leeway)) {
+		return &ClaimError{Claim: "exp", Value: claims.Raw["exp"], Err: ErrTokenExpired}
+	}
+	if !claims.NotBefore.IsZero() && now.Add(p.Leeway).Before(claims.NotBefore) {
+		return &ClaimError{Claim: "nbf", Value: claims.Raw["nbf"], Err: ErrTokenNotValidYet}
+	}
+	if !claims.IssuedAt.IsZero() && now.Add(p.Leeway).Before(claims.IssuedAt) {
+		return &ClaimError{Claim: "iat", Value: claims.Raw["iat"], Err: ErrTokenUsedBeforeIssued}
+	}
+	return nil
+}
+
+func containsAny(a []string, values []string) bool {
+	for _, v := range values {
+		if authorization.Contains(a, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_IssuersAudiences(t *testing.T) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	c.Issuers = []string{"https://iam.xtg.com/"}
+	c.Audiences = []string{"hotelx"}
+	p, err := NewParserE(c)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		iss  interface{}
+		aud  interface{}
+		err  error
+	}{
+		{name: "valid", iss: "https://iam.xtg.com/", aud: "hotelx"},
+		{name: "valid audience list", iss: "https://iam.xtg.com/", aud: []interface{}{"billing", "hotelx"}},
+		{name: "missing issuer", aud: "hotelx", err: ErrInvalidIssuer},
+		{name: "wrong issuer", iss: "https://evil.com/", aud: "hotelx", err: ErrInvalidIssuer},
+		{name: "missing audience", iss: "https://iam.xtg.com/", err: ErrInvalidAudience},
+		{name: "wrong audience", iss: "https://iam.xtg.com/", aud: []interface{}{"billing"}, err: ErrInvalidAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := testClaims()
+			if tt.iss != nil {
+				claims["iss"] = tt.iss
+			}
+			if tt.aud != nil {
+				claims["aud"] = tt.aud
+			}
+			_, err := p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, key, "", claims))
+			if tt.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tt.err), "unexpected error %v", err)
+			var claimErr *ClaimError
+			assert.True(t, errors.As(err, &claimErr))
+		})
+	}
+}
+
+func TestParser_timeClaims(t *testing.T) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	c.Leeway = 30 * time.Second
+	p, err := NewParserE(c)
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	p.Now = func() time.Time { return now }
+	at := func(d time.Duration) float64 { return float64(now.Add(d).Unix()) }
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		err    error
+	}{
+		{name: "valid", claims: jwt.MapClaims{"exp": at(time.Hour), "nbf": at(-time.Hour), "iat": at(-time.Hour)}},
+		{name: "expired within leeway", claims: jwt.MapClaims{"exp": at(-10 * time.Second)}},
+		{name: "expired", claims: jwt.MapClaims{"exp": at(-time.Minute)}, err: ErrTokenExpired},
+		{name: "nbf within leeway", claims: jwt.MapClaims{"exp": at(time.Hour), "nbf": at(10 * time.Second)}},
+		{name: "not valid yet", claims: jwt.MapClaims{"exp": at(time.Hour), "nbf": at(time.Minute)}, err: ErrTokenNotValidYet},
+		{name: "iat within leeway", claims: jwt.MapClaims{"exp": at(time.Hour), "iat": at(10 * time.Second)}},
+		{name: "issued in the future", claims: jwt.MapClaims{"exp": at(time.Hour), "iat": at(time.Minute)}, err: ErrTokenUsedBeforeIssued},
+		{name: "mistyped exp", claims: jwt.MapClaims{"exp": "tomorrow"}, err: ErrInvalidClaim},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.claims["https://xtg.com/member_id"] = "user@xtg.com"
+			_, err := p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, key, "", tt.claims))
+			if tt.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tt.err), "unexpected error %v", err)
+		})
+	}
+}
+
+func TestParser_IgnoreExpiration(t *testing.T) {
+	key := newRSAKey(t)
+	c := testParserConfig()
+	c.PublicKey = publicKeyPEM(t, &key.PublicKey)
+	c.IgnoreExpiration = true
+	p, err := NewParserE(c)
+	require.NoError(t, err)
+
+	claims := testClaims()
+	claims["exp"] = float64(time.Now().Add(-time.Hour).Unix())
+	u, err := p.Parse("Bearer " + signToken(t, jwt.SigningMethodRS256, key, "", claims))
+	require.NoError(t, err)
+	assert.True(t, u.IsExpired)
+}
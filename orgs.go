@@ -0,0 +1,251 @@
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Organization is an organization the User belongs to. It is encoded in tokens as
+// {"o": code, "r": role, "s": [{"c": service, "r": role}]}, a missing role is VIEWER
+type Organization struct {
+	Code     string
+	Role     Role
+	Services []ServiceRole
+}
+
+// ServiceRole is the Role of the User in a Service of an Organization
+type ServiceRole struct {
+	Service Service
+	Role    Role
+}
+
+// EffectiveRole returns the Role in the organization, or in service within it when service is not nil:
+// the greatest of the organization Role and the Role in the service
+func (o Organization) EffectiveRole(service *Service) Role {
+	role := o.Role
+	if service == nil {
+		return role
+	}
+	for _, s := range o.Services {
+		if s.Service == *service && s.Role > role {
+			role = s.Role
+		}
+	}
+	return role
+}
+
+// RoleMatrix holds the effective roles of a User by organization code
+type RoleMatrix map[string]OrgRoles
+
+// OrgRoles are the effective roles of a User in an organization
+type OrgRoles struct {
+	// Role is the organization Role
+	Role Role
+	// Services holds the effective Role in every registered service and in the services of the organization
+	Services map[Service]Role
+}
+
+// EffectiveRoles returns the effective Role of the User in every organization and service, the same
+// Organization.EffectiveRole that GetOrgsServiceFilter applies. An organization listed more than once
+// gets the greatest roles
+func (u User) EffectiveRoles() RoleMatrix {
+	entries := map[string][]Organization{}
+	for _, org := range u.Orgs {
+		entries[org.Code] = append(entries[org.Code], org)
+	}
+
+	m := make(RoleMatrix, len(entries))
+	for code, orgs := range entries {
+		roles := OrgRoles{Role: orgs[0].Role, Services: map[Service]Role{}}
+		services := Services()
+		for _, org := range orgs {
+			if org.Role > roles.Role {
+				roles.Role = org.Role
+			}
+			services = append(services, orgServices(org)...)
+		}
+		for _, s := range services {
+			s := s
+			role := orgs[0].EffectiveRole(&s)
+			for _, org := range orgs[1:] {
+				if r := org.EffectiveRole(&s); r > role {
+					role = r
+				}
+			}
+			roles.Services[s] = role
+		}
+		m[code] = roles
+	}
+	return m
+}
+
+func orgServices(org Organization) []Service {
+	services := make([]Service, 0, len(org.Services))
+	for _, s := range org.Services {
+		services = append(services, s.Service)
+	}
+	return services
+}
+
+// EffectiveRoles returns the RoleMatrix of the User in ctx, empty when there is none
+func EffectiveRoles(ctx context.Context) RoleMatrix {
+	u, _ := UserFromContext(ctx)
+	if u == nil {
+		return RoleMatrix{}
+	}
+	return u.EffectiveRoles()
+}
+
+// Role returns the effective Role in the organization, or in service within it when service is not nil.
+// ok is false when the User doesn't belong to the organization
+func (m RoleMatrix) Role(org string, service *Service) (role Role, ok bool) {
+	roles, ok := m[org]
+	if !ok {
+		return VIEWER, false
+	}
+	return roles.For(service), true
+}
+
+// Orgs returns the sorted codes of the organizations where the effective Role is at least role
+func (m RoleMatrix) Orgs(role Role, service *Service) []string {
+	codes := []string{}
+	for code, roles := range m {
+		if roles.For(service).Includes(role) {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// For returns the effective Role in service, the organization Role for a nil or unlisted service
+func (r OrgRoles) For(service *Service) Role {
+	if service == nil {
+		return r.Role
+	}
+	if role, ok := r.Services[*service]; ok {
+		return role
+	}
+	return r.Role
+}
+
+// Claim returns the claim encoding of the organization. Unregistered roles, which couldn't be parsed
+// back, fail with ErrUnknownRole
+func (o Organization) Claim() (map[string]interface{}, error) {
+	role, err := o.Role.name()
+	if err != nil {
+		return nil, fmt.Errorf("organization %q: %w", o.Code, err)
+	}
+	claim := map[string]interface{}{
+		"o": o.Code,
+		"r": role,
+	}
+	if len(o.Services) > 0 {
+		services := make([]interface{}, 0, len(o.Services))
+		for _, s := range o.Services {
+			role, err := s.Role.name()
+			if err != nil {
+				return nil, fmt.Errorf("organization %q service %s: %w", o.Code, s.Service, err)
+			}
+			services = append(services, map[string]interface{}{
+				"c": string(s.Service),
+				"r": role,
+			})
+		}
+		claim["s"] = services
+	}
+	return claim, nil
+}
+
+func (o Organization) MarshalJSON() ([]byte, error) {
+	claim, err := o.Claim()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(claim)
+}
+
+func (o *Organization) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	org, err := parseOrganization(v)
+	if err != nil {
+		return err
+	}
+	*o = org
+	return nil
+}
+
+// ParseOrganizations decodes the value of an organizations claim, an array of organizations. Entries
+// which aren't objects, without code, or with an unknown role are reported
+func ParseOrganizations(claim interface{}) ([]Organization, error) {
+	entries, ok := claim.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("organizations must be an array, got %T", claim)
+	}
+	orgs := make([]Organization, 0, len(entries))
+	for i, entry := range entries {
+		org, err := parseOrganization(entry)
+		if err != nil {
+			return nil, fmt.Errorf("organization %d: %w", i, err)
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, nil
+}
+
+func parseOrganization(v interface{}) (Organization, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return Organization{}, fmt.Errorf("must be an object, got %T", v)
+	}
+	code, ok := m["o"].(string)
+	if !ok || code == "" {
+		return Organization{}, fmt.Errorf("code o must be a non empty string")
+	}
+	org := Organization{Code: code}
+
+	var err error
+	if org.Role, err = parseRoleClaim(m["r"]); err != nil {
+		return Organization{}, fmt.Errorf("%s: %w", code, err)
+	}
+
+	if s, ok := m["s"]; ok && s != nil {
+		services, ok := s.([]interface{})
+		if !ok {
+			return Organization{}, fmt.Errorf("%s: services s must be an array, got %T", code, s)
+		}
+		for _, sv := range services {
+			sm, ok := sv.(map[string]interface{})
+			if !ok {
+				return Organization{}, fmt.Errorf("%s: service must be an object, got %T", code, sv)
+			}
+			service, ok := sm["c"].(string)
+			if !ok || service == "" {
+				return Organization{}, fmt.Errorf("%s: service code c must be a non empty string", code)
+			}
+			role, err := parseRoleClaim(sm["r"])
+			if err != nil {
+				return Organization{}, fmt.Errorf("%s: service %s: %w", code, service, err)
+			}
+			org.Services = append(org.Services, ServiceRole{Service: Service(service), Role: role})
+		}
+	}
+	return org, nil
+}
+
+// parseRoleClaim decodes the r value of an organization or service, VIEWER when absent
+func parseRoleClaim(v interface{}) (Role, error) {
+	if v == nil {
+		return VIEWER, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return VIEWER, fmt.Errorf("role r must be a string, got %T", v)
+	}
+	return ParseRole(s)
+}
@@ -0,0 +1,135 @@
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOrganizations(t *testing.T) {
+	var claim interface{}
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"o": "org1", "r": "OWNER", "s": [{"c": "ENTITIES", "r": "ADMIN"}]},
+		{"o": "org2"}
+	]`), &claim))
+
+	orgs, err := ParseOrganizations(claim)
+	require.NoError(t, err)
+	assert.Equal(t, []Organization{
+		{Code: "org1", Role: OWNER, Services: []ServiceRole{{Service: ENTITIES, Role: ADMIN}}},
+		{Code: "org2", Role: VIEWER},
+	}, orgs)
+}
+
+func TestParseOrganizations_malformed(t *testing.T) {
+	tests := map[string]string{
+		"not an array":  `{"o": "org1"}`,
+		"not an object": `["org1"]`,
+		"missing code":  `[{"r": "ADMIN"}]`,
+		"unknown role":  `[{"o": "org1", "r": "SUPERUSER"}]`,
+		"role type":     `[{"o": "org1", "r": 2}]`,
+		"services type": `[{"o": "org1", "s": {"c": "ENTITIES"}}]`,
+		"service code":  `[{"o": "org1", "s": [{"r": "ADMIN"}]}]`,
+		"service role":  `[{"o": "org1", "s": [{"c": "ENTITIES", "r": "admin"}]}]`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			var claim interface{}
+			require.NoError(t, json.Unmarshal([]byte(data), &claim))
+			_, err := ParseOrganizations(claim)
+			assert.Error(t, err)
+		})
+	}
+
+	var claim interface{}
+	json.Unmarshal([]byte(`[{"o": "org1", "r": "SUPERUSER"}]`), &claim)
+	_, err := ParseOrganizations(claim)
+	assert.True(t, errors.Is(err, ErrUnknownRole))
+}
+
+func TestOrganization_JSON(t *testing.T) {
+	orgs := []Organization{
+		{Code: "org1", Role: EDITOR, Services: []ServiceRole{{Service: BILLING, Role: OWNER}}},
+		{Code: "org2", Role: VIEWER},
+	}
+	data, err := json.Marshal(orgs)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"o": "org1", "r": "EDITOR", "s": [{"c": "BILLING", "r": "OWNER"}]}, {"o": "org2", "r": "VIEWER"}]`, string(data))
+
+	var decoded []Organization
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, orgs, decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`[{"o": "org1", "r": "ROOT"}]`), &decoded))
+
+	// unregistered roles couldn't be parsed back
+	_, err = json.Marshal(Organization{Code: "org1", Role: Role(42)})
+	assert.True(t, errors.Is(err, ErrUnknownRole))
+	_, err = Organization{Code: "org1", Services: []ServiceRole{{Service: BILLING, Role: Role(42)}}}.Claim()
+	assert.True(t, errors.Is(err, ErrUnknownRole))
+}
+
+func TestOrganization_EffectiveRole(t *testing.T) {
+	entities, billing := ENTITIES, BILLING
+	org := Organization{Code: "org1", Role: EDITOR, Services: []ServiceRole{
+		{Service: ENTITIES, Role: VIEWER},
+		{Service: BILLING, Role: OWNER},
+	}}
+	assert.Equal(t, EDITOR, org.EffectiveRole(nil))
+	assert.Equal(t, EDITOR, org.EffectiveRole(&entities))
+	assert.Equal(t, OWNER, org.EffectiveRole(&billing))
+}
+
+func TestUser_EffectiveRoles(t *testing.T) {
+	u := User{Orgs: []Organization{
+		{Code: "org1", Role: EDITOR, Services: []ServiceRole{{Service: BILLING, Role: OWNER}}},
+		{Code: "org2", Role: VIEWER, Services: []ServiceRole{{Service: "CUSTOM", Role: ADMIN}}},
+		{Code: "org2", Role: ADMIN},
+	}}
+
+	m := u.EffectiveRoles()
+	assert.Equal(t, RoleMatrix{
+		"org1": {Role: EDITOR, Services: map[Service]Role{ENTITIES: EDITOR, BILLING: OWNER, HOTELX: EDITOR}},
+		"org2": {Role: ADMIN, Services: map[Service]Role{ENTITIES: ADMIN, BILLING: ADMIN, HOTELX: ADMIN, "CUSTOM": ADMIN}},
+	}, m)
+
+	billing, unknown := BILLING, UNKNOWN
+	role, ok := m.Role("org1", &billing)
+	assert.True(t, ok)
+	assert.Equal(t, OWNER, role)
+	role, ok = m.Role("org1", &unknown)
+	assert.True(t, ok)
+	assert.Equal(t, EDITOR, role)
+	_, ok = m.Role("org3", nil)
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"org1", "org2"}, m.Orgs(ADMIN, &billing))
+	assert.Equal(t, []string{"org2"}, m.Orgs(ADMIN, nil))
+
+	// the matrix agrees with GetOrgsServiceFilter, which lists an org once per entry
+	for _, r := range []Role{VIEWER, EDITOR, ADMIN, OWNER} {
+		for _, s := range []Service{ENTITIES, BILLING, HOTELX} {
+			s := s
+			filtered := map[string]bool{}
+			for _, code := range u.GetOrgsServiceFilter(r, &s) {
+				filtered[code] = true
+			}
+			assert.Len(t, m.Orgs(r, &s), len(filtered))
+			for _, code := range m.Orgs(r, &s) {
+				assert.True(t, filtered[code], code)
+			}
+		}
+	}
+}
+
+func TestEffectiveRoles_context(t *testing.T) {
+	assert.Empty(t, EffectiveRoles(context.Background()))
+
+	u := &User{Orgs: []Organization{{Code: "org1", Role: ADMIN}}}
+	m := EffectiveRoles(ContextWithUser(context.Background(), u))
+	assert.Equal(t, ADMIN, m["org1"].Role)
+}
@@ -25,3 +25,66 @@ type Permissions interface {
 	// GetParents returns all the parent groups of a given group.
 	GetParents(group string) map[string]interface{}
 }
+
+var (
+	_ Permissions = AllowAllPermissions{}
+	_ Permissions = DenyAllPermissions{}
+)
+
+// AllowAllPermissions grants every permission, it holds no groups
+type AllowAllPermissions struct{}
+
+// CheckPermission returns the requested specials, or "all" when none is requested
+func (AllowAllPermissions) CheckPermission(product string, object string, permission Permission, specials ...string) ([]string, bool) {
+	if len(specials) > 0 {
+		return specials, true
+	}
+	return []string{"all"}, true
+}
+
+func (AllowAllPermissions) ValidGroups(product string, object string, permission Permission) map[string]struct{} {
+	return map[string]struct{}{"all": {}}
+}
+
+func (AllowAllPermissions) GetGroups(groupType string) []string {
+	return nil
+}
+
+func (AllowAllPermissions) GetAllGroups() map[string]struct{} {
+	return map[string]struct{}{}
+}
+
+func (AllowAllPermissions) GetGroupsByTypes() map[string][]string {
+	return map[string][]string{}
+}
+
+func (AllowAllPermissions) GetParents(group string) map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+// DenyAllPermissions grants no permission, it holds no groups
+type DenyAllPermissions struct{}
+
+func (DenyAllPermissions) CheckPermission(product string, object string, permission Permission, specials ...string) ([]string, bool) {
+	return nil, false
+}
+
+func (DenyAllPermissions) ValidGroups(product string, object string, permission Permission) map[string]struct{} {
+	return nil
+}
+
+func (DenyAllPermissions) GetGroups(groupType string) []string {
+	return nil
+}
+
+func (DenyAllPermissions) GetAllGroups() map[string]struct{} {
+	return map[string]struct{}{}
+}
+
+func (DenyAllPermissions) GetGroupsByTypes() map[string][]string {
+	return map[string][]string{}
+}
+
+func (DenyAllPermissions) GetParents(group string) map[string]interface{} {
+	return map[string]interface{}{}
+}
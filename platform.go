@@ -0,0 +1,152 @@
+package authorization
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+)
+
+// PlatformPolicy describes the platform operator organizations, whose staff run the platform, and
+// what that staff may do in the other organizations. The zero value is the historical behaviour: ORG_TGX
+// is the platform organization, the membership claim is required and staff don't act across
+// organizations
+type PlatformPolicy struct {
+	// Orgs are the codes of the platform organizations, ORG_TGX when empty
+	Orgs []string
+	// IgnoreMemberClaim makes a role in a platform organization enough to be staff, otherwise the
+	// membership claim (User.TgxMember) is required too
+	IgnoreMemberClaim bool
+	// MaxCrossOrgRole is the greatest Role staff act with in other organizations, their role in the
+	// platform organizations is capped to it. Nil doesn't let staff act across organizations
+	MaxCrossOrgRole *Role
+	// CrossOrgAllowed restricts the organizations staff act in, every organization when nil
+	CrossOrgAllowed func(org string) bool
+}
+
+var platformPolicy atomic.Pointer[PlatformPolicy]
+
+// SetPlatformPolicy sets the PlatformPolicy of IsTGXMemberRole, IsPlatformStaff and CanActInOrg
+func SetPlatformPolicy(p PlatformPolicy) {
+	platformPolicy.Store(&p)
+}
+
+// GetPlatformPolicy returns the PlatformPolicy set with SetPlatformPolicy, the zero PlatformPolicy
+// when none was set
+func GetPlatformPolicy() PlatformPolicy {
+	if p := platformPolicy.Load(); p != nil {
+		return *p
+	}
+	return PlatformPolicy{}
+}
+
+// IsPlatformOrg reports whether org is a platform organization
+func (p PlatformPolicy) IsPlatformOrg(org string) bool {
+	if len(p.Orgs) == 0 {
+		return org == ORG_TGX
+	}
+	return Contains(p.Orgs, org)
+}
+
+// StaffRole returns the greatest effective Role of u in service within the platform organizations.
+// ok is false when u isn't staff
+func (p PlatformPolicy) StaffRole(u User, service *Service) (role Role, ok bool) {
+	if !p.IgnoreMemberClaim && !u.IsTGXMember() {
+		return VIEWER, false
+	}
+	for _, org := range u.Orgs {
+		if !p.IsPlatformOrg(org.Code) {
+			continue
+		}
+		if r := org.EffectiveRole(service); !ok || r > role {
+			role = r
+		}
+		ok = true
+	}
+	return role, ok
+}
+
+// IsStaff reports whether u is platform staff, a member of a platform organization and, unless
+// IgnoreMemberClaim, carrying the membership claim
+func (p PlatformPolicy) IsStaff(u User) bool {
+	_, ok := p.StaffRole(u, nil)
+	return ok
+}
+
+// HasStaffRole reports whether u is staff granted role in service within the platform organizations
+func (p PlatformPolicy) HasStaffRole(u User, role Role, service *Service) bool {
+	r, ok := p.StaffRole(u, service)
+	return ok && r.Includes(role)
+}
+
+// CrossOrgRole returns the Role staff act with in org: their StaffRole capped to MaxCrossOrgRole. ok is
+// false when u isn't staff or the policy doesn't let staff act in org
+func (p PlatformPolicy) CrossOrgRole(u User, org string, service *Service) (role Role, ok bool) {
+	if p.MaxCrossOrgRole == nil || (p.CrossOrgAllowed != nil && !p.CrossOrgAllowed(org)) {
+		return VIEWER, false
+	}
+	if role, ok = p.StaffRole(u, service); !ok {
+		return VIEWER, false
+	}
+	if role > *p.MaxCrossOrgRole {
+		role = *p.MaxCrossOrgRole
+	}
+	return role, true
+}
+
+// CanAct reports whether u is granted role in service within org, through its own organizations or as
+// staff acting across organizations
+func (p PlatformPolicy) CanAct(u User, org string, role Role, service *Service) bool {
+	if Contains(u.GetOrgsServiceFilter(role, service), org) {
+		return true
+	}
+	r, ok := p.CrossOrgRole(u, org, service)
+	return ok && r.Includes(role)
+}
+
+// ActingOrgs returns the sorted codes of the organizations of u where it's granted role in service.
+// all is true when u may also act with role in every other organization the policy allows, which
+// is every organization when CrossOrgAllowed is nil
+func (p PlatformPolicy) ActingOrgs(u User, role Role, service *Service) (orgs []string, all bool) {
+	seen := map[string]bool{}
+	orgs = []string{}
+	for _, code := range u.GetOrgsServiceFilter(role, service) {
+		if !seen[code] {
+			seen[code] = true
+			orgs = append(orgs, code)
+		}
+	}
+	sort.Strings(orgs)
+
+	if p.MaxCrossOrgRole != nil {
+		r, ok := p.StaffRole(u, service)
+		if r > *p.MaxCrossOrgRole {
+			r = *p.MaxCrossOrgRole
+		}
+		all = ok && r.Includes(role)
+	}
+	return orgs, all
+}
+
+// IsPlatformStaff reports whether the User is staff under the PlatformPolicy set with SetPlatformPolicy
+func (u User) IsPlatformStaff() bool {
+	return GetPlatformPolicy().IsStaff(u)
+}
+
+// CanActInOrg reports whether the User is granted role in service within org under the PlatformPolicy
+// set with SetPlatformPolicy
+func (u User) CanActInOrg(org string, role Role, service *Service) bool {
+	return GetPlatformPolicy().CanAct(u, org, role, service)
+}
+
+// IsPlatformStaff reports whether the User in ctx is platform staff, false when there is none
+func IsPlatformStaff(ctx context.Context) bool {
+	u, _ := UserFromContext(ctx)
+	return u != nil && u.IsPlatformStaff()
+}
+
+// CanActInOrg reports whether the User in ctx is granted role in service within org, false when there
+// is none
+func CanActInOrg(ctx context.Context, org string, role Role, service *Service) bool {
+	u, _ := UserFromContext(ctx)
+	return u != nil && u.CanActInOrg(org, role, service)
+}
@@ -0,0 +1,100 @@
+package authorization
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withPlatformPolicy sets p for the test, restoring the previous policy when it ends
+func withPlatformPolicy(t *testing.T, p PlatformPolicy) {
+	saved := platformPolicy.Load()
+	SetPlatformPolicy(p)
+	t.Cleanup(func() { platformPolicy.Store(saved) })
+}
+
+func TestPlatformPolicy_zero(t *testing.T) {
+	var p PlatformPolicy
+	staff := User{TgxMember: true, Orgs: []Organization{{Code: ORG_TGX, Role: ADMIN}, {Code: "org1", Role: VIEWER}}}
+
+	assert.True(t, p.IsStaff(staff))
+	assert.True(t, p.HasStaffRole(staff, ADMIN, nil))
+	assert.False(t, p.HasStaffRole(staff, OWNER, nil))
+
+	noClaim := staff
+	noClaim.TgxMember = false
+	assert.False(t, p.IsStaff(noClaim))
+	assert.False(t, p.IsStaff(User{TgxMember: true, Orgs: []Organization{{Code: "org1", Role: OWNER}}}))
+
+	// staff don't act across organizations by default
+	assert.True(t, p.CanAct(staff, "org1", VIEWER, nil))
+	assert.False(t, p.CanAct(staff, "org1", EDITOR, nil))
+	assert.False(t, p.CanAct(staff, "org2", VIEWER, nil))
+}
+
+func TestPlatformPolicy_orgs(t *testing.T) {
+	p := PlatformPolicy{Orgs: []string{"ops", "support"}, IgnoreMemberClaim: true}
+	billing := BILLING
+	u := User{Orgs: []Organization{
+		{Code: "support", Role: VIEWER, Services: []ServiceRole{{Service: BILLING, Role: EDITOR}}},
+		{Code: ORG_TGX, Role: OWNER},
+	}}
+
+	assert.True(t, p.IsPlatformOrg("ops"))
+	assert.False(t, p.IsPlatformOrg(ORG_TGX))
+	assert.True(t, p.IsStaff(u), "the member claim is ignored")
+	role, ok := p.StaffRole(u, &billing)
+	assert.True(t, ok)
+	assert.Equal(t, EDITOR, role)
+	role, _ = p.StaffRole(u, nil)
+	assert.Equal(t, VIEWER, role, "tgx isn't a platform org")
+}
+
+func TestPlatformPolicy_crossOrg(t *testing.T) {
+	editor := EDITOR
+	p := PlatformPolicy{
+		MaxCrossOrgRole: &editor,
+		CrossOrgAllowed: func(org string) bool { return org != "private" },
+	}
+	staff := User{TgxMember: true, Orgs: []Organization{{Code: ORG_TGX, Role: OWNER}, {Code: "org1", Role: OWNER}}}
+
+	role, ok := p.CrossOrgRole(staff, "org2", nil)
+	assert.True(t, ok)
+	assert.Equal(t, EDITOR, role, "capped")
+	assert.True(t, p.CanAct(staff, "org2", EDITOR, nil))
+	assert.False(t, p.CanAct(staff, "org2", ADMIN, nil))
+	assert.True(t, p.CanAct(staff, "org1", OWNER, nil), "own organizations keep their role")
+	assert.False(t, p.CanAct(staff, "private", VIEWER, nil))
+
+	orgs, all := p.ActingOrgs(staff, EDITOR, nil)
+	assert.Equal(t, []string{"org1", "tgx"}, orgs)
+	assert.True(t, all)
+	_, all = p.ActingOrgs(staff, ADMIN, nil)
+	assert.False(t, all)
+
+	notStaff := User{Orgs: []Organization{{Code: ORG_TGX, Role: OWNER}}}
+	assert.False(t, p.CanAct(notStaff, "org2", VIEWER, nil))
+	_, all = p.ActingOrgs(notStaff, VIEWER, nil)
+	assert.False(t, all)
+}
+
+func TestSetPlatformPolicy(t *testing.T) {
+	viewer := VIEWER
+	withPlatformPolicy(t, PlatformPolicy{Orgs: []string{"ops"}, MaxCrossOrgRole: &viewer})
+
+	u := &User{TgxMember: true, Orgs: []Organization{{Code: "ops", Role: ADMIN}}}
+	assert.True(t, u.IsTGXMemberRole(ADMIN, nil))
+	assert.True(t, u.IsPlatformStaff())
+
+	ctx := ContextWithUser(context.Background(), u)
+	assert.True(t, IsPlatformStaff(ctx))
+	assert.True(t, CanActInOrg(ctx, "org1", VIEWER, nil))
+	assert.False(t, CanActInOrg(ctx, "org1", EDITOR, nil))
+
+	assert.False(t, IsPlatformStaff(context.Background()))
+	assert.False(t, CanActInOrg(context.Background(), "org1", VIEWER, nil))
+
+	tgx := &User{TgxMember: true, Orgs: []Organization{{Code: ORG_TGX, Role: OWNER}}}
+	assert.False(t, tgx.IsTGXMemberRole(VIEWER, nil), "tgx is no longer a platform org")
+}
@@ -0,0 +1,24 @@
+package authorization
+
+// ErrRevoked is returned by parsers when the credentials of a User have been revoked
+var ErrRevoked error = NewError("credentials revoked", ErrUnauthenticated)
+
+// Revoker reports whether the credentials a User was built from have been revoked before they expired.
+// Implementations can be found in the revocation subpackage
+type Revoker interface {
+	IsRevoked(u *User) (bool, error)
+}
+
+// IsRevoked reports whether r revoked the credentials of u: the token it was built from or, for a fetch
+// needed User, the short token it was upgraded from
+func IsRevoked(r Revoker, u *User) (bool, error) {
+	if revoked, err := r.IsRevoked(u); revoked || err != nil {
+		return revoked, err
+	}
+	if u == nil || (u.ShortTokenID == "" && u.ShortIssuedAt == 0) {
+		return false, nil
+	}
+	short := *u
+	short.TokenID, short.IssuedAt = u.ShortTokenID, u.ShortIssuedAt
+	return r.IsRevoked(&short)
+}
@@ -0,0 +1,99 @@
+package revocation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+var _ authorization.Revoker = (*FileRevoker)(nil)
+
+// FileRevoker is a Revoker backed by a JSON encoded List file which is polled for changes. When the
+// file can't be read or decoded the last good List keeps being used
+type FileRevoker struct {
+	path    string
+	revoker *MemoryRevoker
+
+	mu       sync.Mutex
+	lastData []byte
+	lastErr  error
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFileRevoker loads the List at path and polls it every interval until Close is called
+func NewFileRevoker(path string, interval time.Duration) (*FileRevoker, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("revocation: polling interval must be positive")
+	}
+	r := &FileRevoker{
+		path:    path,
+		revoker: NewMemoryRevoker(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	go r.poll(interval)
+	return r, nil
+}
+
+// IsRevoked implements authorization.Revoker
+func (r *FileRevoker) IsRevoked(u *authorization.User) (bool, error) {
+	return r.revoker.IsRevoked(u)
+}
+
+// Reload reads the file, the List is only replaced when the file content changed
+func (r *FileRevoker) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := os.ReadFile(r.path)
+	if err == nil && !bytes.Equal(data, r.lastData) {
+		var l List
+		if err = json.Unmarshal(data, &l); err == nil {
+			r.revoker.Replace(l)
+			r.lastData = data
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("revocation: loading %s: %v", r.path, err)
+	}
+	r.lastErr = err
+	return err
+}
+
+// Err returns the error of the last reload, if any
+func (r *FileRevoker) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastErr
+}
+
+// Close stops polling the file, it's safe to call more than once
+func (r *FileRevoker) Close() {
+	r.closeOnce.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+func (r *FileRevoker) poll(interval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.Reload()
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package revocation
+
+import (
+	"sync"
+	"time"
+
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+var _ authorization.Revoker = (*MemoryRevoker)(nil)
+
+// List is the set of revoked credentials, it's also the JSON format read by FileRevoker
+type List struct {
+	// TokenIDs are revoked jti values
+	TokenIDs []string `json:"token_ids"`
+	// MemberIDs are members whose every token is revoked
+	MemberIDs []string `json:"member_ids"`
+	// IssuedBefore revokes, per member id, the tokens issued before a unix timestamp
+	IssuedBefore map[string]int64 `json:"issued_before"`
+}
+
+// MemoryRevoker is a Revoker backed by in-memory sets, safe for concurrent use
+type MemoryRevoker struct {
+	mu           sync.RWMutex
+	tokens       map[string]struct{}
+	members      map[string]struct{}
+	issuedBefore map[string]time.Time
+}
+
+// NewMemoryRevoker returns an empty MemoryRevoker
+func NewMemoryRevoker() *MemoryRevoker {
+	return &MemoryRevoker{
+		tokens:       map[string]struct{}{},
+		members:      map[string]struct{}{},
+		issuedBefore: map[string]time.Time{},
+	}
+}
+
+// RevokeToken revokes the token with the given jti
+func (r *MemoryRevoker) RevokeToken(tokenID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[tokenID] = struct{}{}
+}
+
+// RevokeMember revokes every token of a member
+func (r *MemoryRevoker) RevokeMember(memberID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.members[memberID] = struct{}{}
+}
+
+// RevokeIssuedBefore revokes the tokens of a member issued before t. Tokens without iat are revoked too
+func (r *MemoryRevoker) RevokeIssuedBefore(memberID string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.issuedBefore[memberID] = t
+}
+
+// Replace swaps every revocation for the ones in l
+func (r *MemoryRevoker) Replace(l List) {
+	tokens := make(map[string]struct{}, len(l.TokenIDs))
+	for _, t := range l.TokenIDs {
+		tokens[t] = struct{}{}
+	}
+	members := make(map[string]struct{}, len(l.MemberIDs))
+	for _, m := range l.MemberIDs {
+		members[m] = struct{}{}
+	}
+	issuedBefore := make(map[string]time.Time, len(l.IssuedBefore))
+	for m, ts := range l.IssuedBefore {
+		issuedBefore[m] = time.Unix(ts, 0)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens, r.members, r.issuedBefore = tokens, members, issuedBefore
+}
+
+// IsRevoked implements authorization.Revoker
+func (r *MemoryRevoker) IsRevoked(u *authorization.User) (bool, error) {
+	if u == nil {
+		return false, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.tokens[u.TokenID]; ok && u.TokenID != "" {
+		return true, nil
+	}
+	for _, id := range u.UserID {
+		if _, ok := r.members[id]; ok {
+			return true, nil
+		}
+		if t, ok := r.issuedBefore[id]; ok && time.Unix(int64(u.IssuedAt), 0).Before(t) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
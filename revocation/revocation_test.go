@@ -0,0 +1,84 @@
+package revocation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization "github.com/travelgateX/go-jwt-tools"
+)
+
+func TestMemoryRevoker(t *testing.T) {
+	issued := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := func(jti, member string, iat time.Time) *authorization.User {
+		return &authorization.User{TokenID: jti, UserID: []string{member}, IssuedAt: float64(iat.Unix())}
+	}
+
+	r := NewMemoryRevoker()
+	r.RevokeToken("leaked")
+	r.RevokeMember("fired@xtg.com")
+	r.RevokeIssuedBefore("rotated@xtg.com", issued)
+
+	tests := []struct {
+		name    string
+		user    *authorization.User
+		revoked bool
+	}{
+		{"valid", user("t1", "a@xtg.com", issued), false},
+		{"revoked token", user("leaked", "a@xtg.com", issued), true},
+		{"revoked member", user("t2", "fired@xtg.com", issued), true},
+		{"issued before", user("t3", "rotated@xtg.com", issued.Add(-time.Second)), true},
+		{"issued after", user("t4", "rotated@xtg.com", issued.Add(time.Second)), false},
+		{"no iat", &authorization.User{TokenID: "t5", UserID: []string{"rotated@xtg.com"}}, true},
+		{"no jti", user("", "a@xtg.com", issued), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := r.IsRevoked(tt.user)
+			require.NoError(t, err)
+			assert.Equal(t, tt.revoked, revoked)
+		})
+	}
+}
+
+func TestFileRevoker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"token_ids": ["leaked"]}`), 0o600))
+
+	r, err := NewFileRevoker(path, 10*time.Millisecond)
+	require.NoError(t, err)
+	defer r.Close()
+
+	leaked := &authorization.User{TokenID: "leaked"}
+	fired := &authorization.User{TokenID: "t1", UserID: []string{"fired@xtg.com"}}
+
+	revoked, _ := r.IsRevoked(leaked)
+	assert.True(t, revoked)
+	revoked, _ = r.IsRevoked(fired)
+	assert.False(t, revoked)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"member_ids": ["fired@xtg.com"]}`), 0o600))
+	assert.Eventually(t, func() bool {
+		revoked, _ := r.IsRevoked(fired)
+		return revoked
+	}, time.Second, 5*time.Millisecond)
+	revoked, _ = r.IsRevoked(leaked)
+	assert.False(t, revoked)
+
+	// a broken file keeps the last good list
+	require.NoError(t, os.WriteFile(path, []byte(`{`), 0o600))
+	assert.Eventually(t, func() bool { return r.Err() != nil }, time.Second, 5*time.Millisecond)
+	revoked, _ = r.IsRevoked(fired)
+	assert.True(t, revoked)
+
+	// closed again by the deferred call
+	r.Close()
+}
+
+func TestNewFileRevoker_missingFile(t *testing.T) {
+	_, err := NewFileRevoker(filepath.Join(t.TempDir(), "missing.json"), time.Second)
+	assert.Error(t, err)
+}
@@ -1,5 +1,21 @@
 package authorization
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrUnknownRole is returned by ParseRole for a string that names no registered Role
+	ErrUnknownRole = errors.New("unknown role")
+	// ErrUnknownService is returned by ParseService for a string that names no registered Service
+	ErrUnknownService = errors.New("unknown service")
+)
+
+// Role is the rank of a role, a greater Role includes every permission of the lower ones. Besides the
+// built in roles applications can register their own with RegisterRole
 type Role int
 
 const (
@@ -9,6 +25,8 @@ const (
 	OWNER  Role = 3
 )
 
+// Service is the code of a product. Besides the built in services applications can register their own
+// with RegisterService
 type Service string
 
 const (
@@ -18,30 +36,218 @@ const (
 	UNKNOWN  Service = "UNKNOWN"
 )
 
+// ORG_TGX is the platform organization of a PlatformPolicy without Orgs
 const ORG_TGX = "tgx"
 
-func GetRoleFromString(role string) Role {
-	switch role {
-	case "OWNER":
-		return OWNER
-	case "ADMIN":
-		return ADMIN
-	case "EDITOR":
-		return EDITOR
-	default:
-		return VIEWER
+// registry holds the registered roles and services. Registration is meant to happen at init, before
+// any token is parsed, but it's safe at any time
+var registry = newRegistry()
+
+type roleRegistry struct {
+	mu       sync.RWMutex
+	roles    map[string]Role
+	names    map[Role]string
+	implies  map[Role][]Role
+	services []Service
+}
+
+func newRegistry() *roleRegistry {
+	r := &roleRegistry{
+		roles:   map[string]Role{},
+		names:   map[Role]string{},
+		implies: map[Role][]Role{},
 	}
+	for _, role := range []struct {
+		name string
+		role Role
+	}{{"VIEWER", VIEWER}, {"EDITOR", EDITOR}, {"ADMIN", ADMIN}, {"OWNER", OWNER}} {
+		r.roles[role.name] = role.role
+		r.names[role.role] = role.name
+	}
+	r.services = []Service{ENTITIES, BILLING, HOTELX}
+	return r
 }
 
-func GetServiceFromString(service string) Service {
-	switch service {
-	case "ENTITIES":
-		return ENTITIES
-	case "BILLING":
-		return BILLING
-	case "HOTELX":
-		return HOTELX
-	default:
-		return UNKNOWN
+// RegisterRole registers the Role named name with rank role. The rank orders it against the other
+// roles, so a Role above OWNER is granted everything an OWNER is. implies lists roles it's granted
+// regardless of the ordering, e.g. a role below VIEWER that still implies VIEWER. Names and ranks
+// can't be registered twice
+func RegisterRole(name string, role Role, implies ...Role) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if name == "" {
+		return fmt.Errorf("role name can't be empty")
+	}
+	if _, ok := registry.roles[name]; ok {
+		return fmt.Errorf("role %q is already registered", name)
+	}
+	if other, ok := registry.names[role]; ok {
+		return fmt.Errorf("role %q: rank %d is already registered as %q", name, role, other)
+	}
+	for _, i := range implies {
+		if _, ok := registry.names[i]; !ok {
+			return fmt.Errorf("role %q implies %w %d", name, ErrUnknownRole, i)
+		}
+	}
+	registry.roles[name] = role
+	registry.names[role] = name
+	if len(implies) > 0 {
+		registry.implies[role] = append([]Role{}, implies...)
+	}
+	return nil
+}
+
+// RegisterService registers the Service named name, which ParseService then accepts and every
+// RoleMatrix lists
+func RegisterService(name string) (Service, error) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if name == "" {
+		return "", fmt.Errorf("service name can't be empty")
+	}
+	s := Service(name)
+	if s == UNKNOWN {
+		return "", fmt.Errorf("service %q is reserved", name)
+	}
+	for _, registered := range registry.services {
+		if registered == s {
+			return "", fmt.Errorf("service %q is already registered", name)
+		}
+	}
+	registry.services = append(registry.services, s)
+	return s, nil
+}
+
+// Services returns the registered services in registration order
+func Services() []Service {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return append([]Service{}, registry.services...)
+}
+
+// ParseRole returns the registered Role named role, unlike GetRoleFromString unknown names are reported
+func ParseRole(role string) (Role, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	r, ok := registry.roles[role]
+	if !ok {
+		return VIEWER, fmt.Errorf("%w %q", ErrUnknownRole, role)
+	}
+	return r, nil
+}
+
+// ParseService returns the registered Service named service, unlike GetServiceFromString unknown
+// names are reported
+func ParseService(service string) (Service, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	for _, s := range registry.services {
+		if string(s) == service {
+			return s, nil
+		}
+	}
+	return UNKNOWN, fmt.Errorf("%w %q", ErrUnknownService, service)
+}
+
+// Includes reports whether r is granted role, because it ranks at least as high or because it implies
+// it, directly or through the roles it implies
+func (r Role) Includes(role Role) bool {
+	if r >= role {
+		return true
+	}
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	seen := map[Role]bool{r: true}
+	pending := registry.implies[r]
+	for len(pending) > 0 {
+		i := pending[0]
+		pending = pending[1:]
+		if i >= role {
+			return true
+		}
+		if !seen[i] {
+			seen[i] = true
+			pending = append(pending, registry.implies[i]...)
+		}
+	}
+	return false
+}
+
+// String returns the registered name of the Role
+func (r Role) String() string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	if name, ok := registry.names[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	name, err := r.name()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(name)
+}
+
+// name returns the registered name of the Role, unlike String unregistered roles are reported
+func (r Role) name() (string, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	name, ok := registry.names[r]
+	if !ok {
+		return "", fmt.Errorf("%w %d", ErrUnknownRole, int(r))
+	}
+	return name, nil
+}
+
+func (r *Role) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("role must be a string: %w", err)
 	}
+	role, err := ParseRole(name)
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+func (s Service) String() string {
+	return string(s)
+}
+
+func (s Service) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s))
+}
+
+func (s *Service) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("service must be a string: %w", err)
+	}
+	service, err := ParseService(name)
+	if err != nil {
+		return err
+	}
+	*s = service
+	return nil
+}
+
+// GetRoleFromString returns the registered Role named role, VIEWER when unknown. Use ParseRole to
+// tell unknown names apart
+func GetRoleFromString(role string) Role {
+	r, _ := ParseRole(role)
+	return r
+}
+
+// GetServiceFromString returns the registered Service named service, UNKNOWN when unknown. Use
+// ParseService to tell unknown names apart
+func GetServiceFromString(service string) Service {
+	s, _ := ParseService(service)
+	return s
 }
@@ -0,0 +1,123 @@
+package authorization
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withRegistry gives the test a fresh registry, restored when it ends
+func withRegistry(t *testing.T) {
+	saved := registry
+	registry = newRegistry()
+	t.Cleanup(func() { registry = saved })
+}
+
+func TestParseRole(t *testing.T) {
+	for name, want := range map[string]Role{"VIEWER": VIEWER, "EDITOR": EDITOR, "ADMIN": ADMIN, "OWNER": OWNER} {
+		r, err := ParseRole(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, r)
+		assert.Equal(t, name, r.String())
+	}
+
+	_, err := ParseRole("owner")
+	assert.True(t, errors.Is(err, ErrUnknownRole))
+	assert.Equal(t, VIEWER, GetRoleFromString("owner"))
+}
+
+func TestRegisterRole(t *testing.T) {
+	withRegistry(t)
+
+	require.NoError(t, RegisterRole("SUPERADMIN", 10))
+	require.NoError(t, RegisterRole("AUDITOR", -1, VIEWER))
+	require.NoError(t, RegisterRole("BILLING_CLERK", -2, -1))
+
+	r, err := ParseRole("SUPERADMIN")
+	require.NoError(t, err)
+	assert.Equal(t, Role(10), r)
+	assert.Equal(t, "AUDITOR", Role(-1).String())
+	assert.Equal(t, "Role(7)", Role(7).String())
+
+	assert.True(t, Role(10).Includes(OWNER))
+	assert.False(t, OWNER.Includes(10))
+	assert.True(t, Role(-1).Includes(VIEWER))
+	assert.False(t, Role(-1).Includes(EDITOR))
+	assert.True(t, Role(-2).Includes(VIEWER), "implied through AUDITOR")
+	assert.True(t, VIEWER.Includes(-1), "ranks above")
+
+	assert.Error(t, RegisterRole("AUDITOR", 20))
+	assert.Error(t, RegisterRole("OTHER", ADMIN))
+	assert.Error(t, RegisterRole("", 21))
+	err = RegisterRole("OTHER", 22, 99)
+	assert.True(t, errors.Is(err, ErrUnknownRole))
+}
+
+func TestRegisterService(t *testing.T) {
+	withRegistry(t)
+
+	_, err := ParseService("TRANSFERS")
+	assert.True(t, errors.Is(err, ErrUnknownService))
+	assert.Equal(t, UNKNOWN, GetServiceFromString("TRANSFERS"))
+
+	s, err := RegisterService("TRANSFERS")
+	require.NoError(t, err)
+	assert.Equal(t, Service("TRANSFERS"), s)
+	assert.Equal(t, s, GetServiceFromString("TRANSFERS"))
+	assert.Equal(t, []Service{ENTITIES, BILLING, HOTELX, s}, Services())
+
+	_, err = RegisterService("TRANSFERS")
+	assert.Error(t, err)
+	_, err = RegisterService("UNKNOWN")
+	assert.Error(t, err)
+
+	// registered services are listed by every RoleMatrix
+	m := User{Orgs: []Organization{{Code: "org1", Role: EDITOR}}}.EffectiveRoles()
+	assert.Equal(t, EDITOR, m["org1"].Services[s])
+}
+
+func TestRole_JSON(t *testing.T) {
+	withRegistry(t)
+	require.NoError(t, RegisterRole("AUDITOR", -1, VIEWER))
+
+	data, err := json.Marshal(map[string]Role{"a": ADMIN, "b": -1})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a": "ADMIN", "b": "AUDITOR"}`, string(data))
+
+	var roles map[string]Role
+	require.NoError(t, json.Unmarshal(data, &roles))
+	assert.Equal(t, map[string]Role{"a": ADMIN, "b": -1}, roles)
+
+	_, err = json.Marshal(Role(42))
+	assert.True(t, errors.Is(err, ErrUnknownRole))
+	var r Role
+	assert.True(t, errors.Is(json.Unmarshal([]byte(`"SUPERUSER"`), &r), ErrUnknownRole))
+	assert.Error(t, json.Unmarshal([]byte(`2`), &r))
+}
+
+func TestService_JSON(t *testing.T) {
+	data, err := json.Marshal(BILLING)
+	require.NoError(t, err)
+	assert.Equal(t, `"BILLING"`, string(data))
+
+	var s Service
+	require.NoError(t, json.Unmarshal(data, &s))
+	assert.Equal(t, BILLING, s)
+	assert.True(t, errors.Is(json.Unmarshal([]byte(`"NOPE"`), &s), ErrUnknownService))
+}
+
+func TestOrganization_registeredRole(t *testing.T) {
+	withRegistry(t)
+	require.NoError(t, RegisterRole("AUDITOR", -1, VIEWER))
+
+	var org Organization
+	require.NoError(t, json.Unmarshal([]byte(`{"o": "org1", "r": "AUDITOR"}`), &org))
+	assert.Equal(t, Role(-1), org.Role)
+
+	u := User{Orgs: []Organization{org}}
+	assert.Equal(t, []string{"org1"}, u.GetOrgs(VIEWER))
+	assert.Empty(t, u.GetOrgs(EDITOR))
+}